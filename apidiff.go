@@ -0,0 +1,491 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	apiChangeRemoved = "removed"
+	apiChangeChanged = "changed"
+
+	dirInternal = "internal"
+)
+
+// apiChange define a removed or changed exported identifier between two
+// versions of package.
+type apiChange struct {
+	// pkgPath is the import path of package that declare the
+	// identifier.
+	pkgPath string
+
+	// name of identifier.
+	// Method, struct field, and interface method is prefixed with their
+	// type name, for example "Env.Sync".
+	name string
+
+	// kind of change, either "removed" or "changed".
+	kind string
+
+	// usedBy contains list of import path of packages that use the
+	// identifier.
+	usedBy []string
+}
+
+// String return the identifier in the form of "<import path>.<name>".
+func (change *apiChange) String() string {
+	return change.pkgPath + "." + change.name
+}
+
+// apiDiff compare the exported API of package between old and new version,
+// using temporary worktree for each version.
+// It will return list of exported identifiers that are removed or changed
+// on the new version, sorted by identifier.
+func (pkg *Package) apiDiff(oldVer, newVer string) (
+	changes []*apiChange, err error,
+) {
+	if pkg.vcsMode != VCSModeGit {
+		return nil, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "beku-apidiff-")
+	if err != nil {
+		return nil, fmt.Errorf("apiDiff: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var (
+		apis = make([]map[string]string, 2)
+		vers = []string{oldVer, newVer}
+	)
+
+	for x, ver := range vers {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("%d", x))
+
+		err = pkg.gitWorktreeAdd(dir, ver)
+		if err != nil {
+			return nil, fmt.Errorf("apiDiff: %w", err)
+		}
+
+		apis[x], err = apiScan(dir, pkg.ImportPath)
+
+		errRemove := pkg.gitWorktreeRemove(dir)
+		if err != nil {
+			return nil, fmt.Errorf("apiDiff: %w", err)
+		}
+		if errRemove != nil {
+			return nil, fmt.Errorf("apiDiff: %w", errRemove)
+		}
+	}
+
+	return apiCompare(apis[0], apis[1]), nil
+}
+
+// apiCompare return list of identifiers in old API that are removed or have
+// different declaration in new API.
+func apiCompare(oldAPI, newAPI map[string]string) (changes []*apiChange) {
+	for ident, oldDecl := range oldAPI {
+		newDecl, ok := newAPI[ident]
+		if ok && newDecl == oldDecl {
+			continue
+		}
+
+		idx := strings.IndexByte(ident, ' ')
+		change := &apiChange{
+			pkgPath: ident[:idx],
+			name:    ident[idx+1:],
+			kind:    apiChangeChanged,
+		}
+		if !ok {
+			change.kind = apiChangeRemoved
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(x, y int) bool {
+		return changes[x].String() < changes[y].String()
+	})
+
+	return changes
+}
+
+// apiScan return the exported API of all non-main and non-internal Go
+// packages inside the root directory.
+// The key of API is "<import path> <identifier>", and the value is the
+// normalized declaration of identifier.
+func apiScan(root, importPath string) (api map[string]string, err error) {
+	api = make(map[string]string)
+
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if path != root && (IsIgnoredDir(fi.Name()) || fi.Name() == dirInternal) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		pkgPath := importPath
+		if rel != "." {
+			pkgPath = importPath + sepImport + filepath.ToSlash(rel)
+		}
+
+		return apiScanDir(api, path, pkgPath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apiScan: %w", err)
+	}
+
+	return api, nil
+}
+
+// apiScanDir add the exported identifiers of Go package in directory into
+// api.
+func apiScanDir(api map[string]string, dir, pkgPath string) (err error) {
+	fset := token.NewFileSet()
+
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.SkipObjectResolution)
+	if err != nil {
+		// Ignore directory that contains invalid Go files, the
+		// package may use build tags or code generator.
+		return nil
+	}
+
+	for name, pkg := range pkgs {
+		if name == "main" {
+			continue
+		}
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				apiScanDecl(api, fset, pkgPath+" ", decl)
+			}
+		}
+	}
+
+	return nil
+}
+
+// apiScanDecl add the exported identifiers in declaration into api, with
+// key prefixed by prefix.
+func apiScanDecl(api map[string]string, fset *token.FileSet, prefix string, decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if !d.Name.IsExported() {
+			return
+		}
+		name := d.Name.Name
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			recv := apiRecvName(d.Recv.List[0].Type)
+			if !ast.IsExported(recv) {
+				return
+			}
+			name = recv + "." + name
+		}
+		api[prefix+name] = apiFuncString(fset, d.Type)
+
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if !s.Name.IsExported() {
+					continue
+				}
+				apiScanType(api, fset, prefix, s)
+
+			case *ast.ValueSpec:
+				decl := d.Tok.String()
+				if s.Type != nil {
+					decl += " " + apiExprString(fset, s.Type)
+				}
+				for _, ident := range s.Names {
+					if ident.IsExported() {
+						api[prefix+ident.Name] = decl
+					}
+				}
+			}
+		}
+	}
+}
+
+// apiScanType add the exported type into api.
+// The exported fields of struct and the methods of interface are added as
+// separate identifiers, so changes on unexported fields does not mark the
+// type as changed.
+func apiScanType(api map[string]string, fset *token.FileSet, prefix string, spec *ast.TypeSpec) {
+	name := spec.Name.Name
+
+	decl := "type"
+	if spec.TypeParams != nil {
+		decl += apiFieldsString(fset, spec.TypeParams, "[", "]")
+	}
+	if spec.Assign.IsValid() {
+		decl += " ="
+	}
+
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		api[prefix+name] = decl + " struct"
+		for _, field := range t.Fields.List {
+			ftype := apiExprString(fset, field.Type)
+			if len(field.Names) == 0 {
+				embedded := apiRecvName(field.Type)
+				if ast.IsExported(embedded) {
+					api[prefix+name+"."+embedded] = ftype
+				}
+				continue
+			}
+			for _, ident := range field.Names {
+				if ident.IsExported() {
+					api[prefix+name+"."+ident.Name] = ftype
+				}
+			}
+		}
+
+	case *ast.InterfaceType:
+		var embeddeds []string
+		for _, field := range t.Methods.List {
+			if len(field.Names) == 0 {
+				embeddeds = append(embeddeds, apiExprString(fset, field.Type))
+				continue
+			}
+			ft, ok := field.Type.(*ast.FuncType)
+			if !ok {
+				continue
+			}
+			for _, ident := range field.Names {
+				api[prefix+name+"."+ident.Name] = apiFuncString(fset, ft)
+			}
+		}
+		api[prefix+name] = decl + " interface{" + strings.Join(embeddeds, "; ") + "}"
+
+	default:
+		api[prefix+name] = decl + " " + apiExprString(fset, spec.Type)
+	}
+}
+
+// apiRecvName return the type name of method receiver or embedded field,
+// without pointer, package qualifier, and type parameters.
+func apiRecvName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.SelectorExpr:
+			return t.Sel.Name
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// apiFuncString return the function signature without parameter names.
+func apiFuncString(fset *token.FileSet, ft *ast.FuncType) string {
+	s := "func"
+	if ft.TypeParams != nil {
+		s += apiFieldsString(fset, ft.TypeParams, "[", "]")
+	}
+	s += apiFieldsString(fset, ft.Params, "(", ")")
+	if ft.Results != nil {
+		s += " " + apiFieldsString(fset, ft.Results, "(", ")")
+	}
+	return s
+}
+
+// apiFieldsString return the list of field types, without their names.
+func apiFieldsString(fset *token.FileSet, fields *ast.FieldList, open, close string) string {
+	var types []string
+
+	for _, field := range fields.List {
+		ftype := apiExprString(fset, field.Type)
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for x := 0; x < n; x++ {
+			types = append(types, ftype)
+		}
+	}
+
+	return open + strings.Join(types, ", ") + close
+}
+
+// apiExprString return the expression as formatted Go code.
+func apiExprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+
+	_ = printer.Fprint(&buf, fset, expr)
+
+	return buf.String()
+}
+
+// apiUsers return true if any Go files inside directory dir use one of
+// the changed identifiers, and add the importPath into the changes usedBy.
+//
+// An identifier is used if the file import the package that declare the
+// identifier and refer the identifier using the package name.
+// Since the type of expression is not resolved, method and field is
+// considered used if the file import the package and have selector with
+// the same name.
+func apiUsers(dir, importPath string, changes []*apiChange) (err error) {
+	used := make([]bool, len(changes))
+
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && IsIgnoredDir(fi.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(fi.Name(), ".go") {
+			return nil
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil
+		}
+
+		apiFileUses(file, changes, used)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("apiUsers: %w", err)
+	}
+
+	for x, change := range changes {
+		if used[x] {
+			change.usedBy = append(change.usedBy, importPath)
+		}
+	}
+
+	return nil
+}
+
+// apiFileUses set the used[x] to true if the file use changes[x].
+func apiFileUses(file *ast.File, changes []*apiChange, used []bool) {
+	// imports map the package name in file to their import path.
+	imports := make(map[string]string)
+
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, "\"`")
+		if imp.Name != nil {
+			imports[imp.Name.Name] = path
+			continue
+		}
+		name := path[strings.LastIndexByte(path, '/')+1:]
+		if idx := strings.Index(name, ".v"); idx > 0 {
+			name = name[:idx]
+		}
+		imports[name] = path
+	}
+
+	var (
+		qualified = make(map[string]bool)
+		selectors = make(map[string]bool)
+	)
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		selectors[sel.Sel.Name] = true
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if path, ok := imports[x.Name]; ok {
+				qualified[path+"."+sel.Sel.Name] = true
+			}
+		}
+		return true
+	})
+
+	isImported := make(map[string]bool, len(imports))
+	for _, path := range imports {
+		isImported[path] = true
+	}
+
+	for x, change := range changes {
+		if used[x] || !isImported[change.pkgPath] {
+			continue
+		}
+
+		idx := strings.IndexByte(change.name, '.')
+		if idx < 0 {
+			used[x] = qualified[change.String()]
+		} else {
+			used[x] = selectors[change.name[idx+1:]]
+		}
+	}
+}
+
+// apiBreaking detect the exported identifiers that are removed or changed
+// on the next version of package, and list the packages that require it
+// and use the identifiers.
+func (env *Env) apiBreaking(pkg *Package) (changes []*apiChange, err error) {
+	changes, err = pkg.apiDiff(pkg.Version, pkg.VersionNext)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	for _, reqBy := range pkg.RequiredBy {
+		_, reqPkg := env.GetPackageFromDB(reqBy, "")
+		if reqPkg == nil {
+			continue
+		}
+
+		err = apiUsers(reqPkg.FullPath, reqPkg.ImportPath, changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// writeAPIChanges write the list of removed or changed identifiers on
+// package and their users into buffer.
+func writeAPIChanges(buf *bytes.Buffer, pkg *Package, changes []*apiChange) {
+	fmt.Fprintf(buf, "\n%s %s...%s\n", pkg.ImportPath, pkg.Version,
+		pkg.VersionNext)
+
+	for _, change := range changes {
+		fmt.Fprintf(buf, "  %-8s %s\n", change.kind, change)
+		if len(change.usedBy) > 0 {
+			fmt.Fprintf(buf, "           used by: %s\n",
+				strings.Join(change.usedBy, ", "))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func testWriteFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAPIScan(t *testing.T) {
+	dir := t.TempDir()
+
+	testWriteFiles(t, dir, map[string]string{
+		"a.go": `package a
+
+const Max = 10
+
+var ErrA error
+
+type T struct {
+	Name string
+	priv int
+}
+
+type I interface {
+	Do(x, y int) error
+}
+
+func New(name string) *T { return nil }
+
+func (t *T) Get() string { return "" }
+
+func (t *T) private() {}
+
+func private() {}
+`,
+		"a_test.go":          "package a\n\nfunc TestOnly() {}\n",
+		"sub/b.go":           "package b\n\nfunc B() {}\n",
+		"internal/c/c.go":    "package c\n\nfunc C() {}\n",
+		"cmd/main/main.go":   "package main\n\nfunc Main() {}\n",
+		"testdata/d/d.go":    "package d\n\nfunc D() {}\n",
+		"invalid/invalid.go": "package invalid\n\nfunc {\n",
+	})
+
+	got, err := apiScan(dir, "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{
+		"example.com/a Max":    "const",
+		"example.com/a ErrA":   "var error",
+		"example.com/a T":      "type struct",
+		"example.com/a T.Name": "string",
+		"example.com/a I":      "type interface{}",
+		"example.com/a I.Do":   "func(int, int) (error)",
+		"example.com/a New":    "func(string) (*T)",
+		"example.com/a T.Get":  "func() (string)",
+		"example.com/a/sub B":  "func()",
+	}
+
+	test.Assert(t, "api", exp, got)
+}
+
+func TestAPICompare(t *testing.T) {
+	oldAPI := map[string]string{
+		"example.com/a New":    "func(string) (*T)",
+		"example.com/a Old":    "func()",
+		"example.com/a T.Name": "string",
+		"example.com/a Same":   "func()",
+	}
+	newAPI := map[string]string{
+		"example.com/a New":    "func(string, int) (*T)",
+		"example.com/a T.Name": "string",
+		"example.com/a Same":   "func()",
+		"example.com/a Added":  "func()",
+	}
+
+	exp := []*apiChange{{
+		pkgPath: "example.com/a",
+		name:    "New",
+		kind:    apiChangeChanged,
+	}, {
+		pkgPath: "example.com/a",
+		name:    "Old",
+		kind:    apiChangeRemoved,
+	}}
+
+	got := apiCompare(oldAPI, newAPI)
+
+	test.Assert(t, "apiCompare", exp, got)
+}
+
+func TestAPIUsers(t *testing.T) {
+	dir := t.TempDir()
+
+	testWriteFiles(t, dir, map[string]string{
+		"main.go": `package main
+
+import (
+	alias "example.com/a"
+	"example.com/a/sub"
+)
+
+func main() {
+	t := alias.New("x")
+	_ = t.Get()
+	sub.B()
+}
+`,
+	})
+
+	changes := []*apiChange{{
+		pkgPath: "example.com/a",
+		name:    "New",
+	}, {
+		pkgPath: "example.com/a",
+		name:    "T.Get",
+	}, {
+		pkgPath: "example.com/a",
+		name:    "Old",
+	}, {
+		pkgPath: "example.com/b",
+		name:    "B",
+	}}
+
+	err := apiUsers(dir, "example.com/main", changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "New usedBy", []string{"example.com/main"}, changes[0].usedBy)
+	test.Assert(t, "T.Get usedBy", []string{"example.com/main"}, changes[1].usedBy)
+	test.Assert(t, "Old usedBy", []string(nil), changes[2].usedBy)
+	test.Assert(t, "B usedBy", []string(nil), changes[3].usedBy)
+}
+
+func TestPackageAPIDiff(t *testing.T) {
+	dir := t.TempDir()
+
+	testGit(t, dir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	testWriteFiles(t, dir, map[string]string{
+		"a.go": "package a\n\nfunc New() {}\n\nfunc Old() {}\n",
+	})
+	testGit(t, dir, "2018-01-01T00:00:00Z", "add", "a.go")
+	testGit(t, dir, "2018-01-01T00:00:00Z", "commit", "--quiet", "-m", "first")
+	testGit(t, dir, "2018-01-01T00:00:00Z", "tag", "v0.1.0")
+
+	testWriteFiles(t, dir, map[string]string{
+		"a.go": "package a\n\nfunc New(x int) {}\n",
+	})
+	testGit(t, dir, "2018-01-02T00:00:00Z", "commit", "--quiet", "-am", "second")
+	testGit(t, dir, "2018-01-02T00:00:00Z", "tag", "v0.2.0")
+
+	pkg := &Package{
+		ImportPath: "example.com/a",
+		FullPath:   dir,
+		vcsMode:    VCSModeGit,
+	}
+
+	got, err := pkg.apiDiff("v0.1.0", "v0.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []*apiChange{{
+		pkgPath: "example.com/a",
+		name:    "New",
+		kind:    apiChangeChanged,
+	}, {
+		pkgPath: "example.com/a",
+		name:    "Old",
+		kind:    apiChangeRemoved,
+	}}
+
+	test.Assert(t, "apiDiff", exp, got)
+}
@@ -43,20 +43,31 @@ const (
 	sectionBeku    = "beku"
 	sectionPackage = "package"
 
-	keyExclude = "exclude"
-
+	keyCompareURL = "compare-url"
+	keyExclude    = "exclude"
+	keyKeyring    = "keyring"
+	keyLicense    = "license"
+	keyLicAllow   = "license-allow"
+	keyRewrite    = "rewrite"
+	keySignature  = "signature"
+
+	keyCommit       = "commit"
 	keyDeps         = "deps"
 	keyDepsMissing  = "missing"
 	keyRemoteName   = "remote-name"
 	keyRemoteURL    = "remote-url"
 	keyRemoteBranch = "remote-branch"
 	keyRequiredBy   = "required-by"
+	keyTree         = "tree"
+	keyUpstreamURL  = "upstream-url"
 	keyVCSMode      = "vcs"
 	keyVersion      = "version"
 
 	gitDefBranch     = "master"
 	gitDefRemoteName = "origin"
 	gitDir           = ".git"
+
+	gitUpstreamName = "upstream"
 )
 
 // List of error messages.
@@ -74,9 +85,48 @@ var (
 	// ErrPackageName define an error if package name is empty or invalid.
 	ErrPackageName = errors.New("empty or invalid package name")
 
-	errDirNotEmpty = "directory %s is not empty"
-	errExcluded    = "package '%s' is in excluded list\n"
-	errVCS         = "unknown VCS mode %s"
+	// ErrDirty define an error when package working tree have local
+	// changes that will be lost if its checked out to other version.
+	ErrDirty = errors.New("working tree has local changes")
+
+	// ErrUnsigned define an error when package version is not signed or
+	// its signature can not be verified.
+	ErrUnsigned = errors.New("version is not signed")
+
+	// ErrVulnerable define an error when packages are affected by known
+	// vulnerabilities.
+	ErrVulnerable = errors.New("vulnerable packages found")
+
+	// ErrLicense define an error when packages have license that is not
+	// allowed.
+	ErrLicense = errors.New("license is not allowed")
+
+	// ErrDrift define an error when packages in "src" directory are
+	// different with the database.
+	ErrDrift = errors.New("drift found between packages and database")
+
+	// ErrVersionMoved define an error when the package version (for
+	// example, a tag) point to different commit or tree than the one
+	// recorded in database.
+	ErrVersionMoved = errors.New("version has been moved")
+
+	// ErrNotMirrored define an error when package repository is not
+	// found in mirror directory.
+	ErrNotMirrored = errors.New("package is not mirrored")
+
+	// ErrHook define an error when the hook command failed.
+	ErrHook = errors.New("hook failed")
+
+	// ErrExcluded define an error when operation is requested on
+	// package that is in excluded list.
+	ErrExcluded = errors.New("package is in excluded list")
+
+	// ErrDirNotEmpty define an error when package directory is not
+	// empty but its not a repository.
+	ErrDirNotEmpty = errors.New("directory is not empty")
+
+	// ErrVCS define an error when package use VCS other than git.
+	ErrVCS = errors.New("unknown VCS mode")
 )
 
 var (
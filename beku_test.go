@@ -50,7 +50,7 @@ func TestMain(m *testing.M) {
 	defStdout = mock.Stdout()
 	defStderr = mock.Stderr()
 
-	testEnv, err = NewEnvironment(false)
+	testEnv, err = NewEnvironment(WithPrefix(testGOPATH))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -60,9 +60,9 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
-	testGitPkgCur, _ = NewPackage(testEnv.dirSrc, testGitRepo, testGitRepo)
-	testGitPkgNew, _ = NewPackage(testEnv.dirSrc, testGitRepo, testGitRepo)
-	testGitPkgInstall, _ = NewPackage(testEnv.dirSrc, testGitRepo, testGitRepo)
+	testGitPkgCur, _ = NewPackage(testEnv.log, testEnv.dirSrc, testGitRepo, testGitRepo)
+	testGitPkgNew, _ = NewPackage(testEnv.log, testEnv.dirSrc, testGitRepo, testGitRepo)
+	testGitPkgInstall, _ = NewPackage(testEnv.log, testEnv.dirSrc, testGitRepo, testGitRepo)
 
 	wd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,167 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+)
+
+// Bisect find the first commit on package pkgName that break the build or
+// tests of package testPkg.
+//
+// The commits are walked between the package version in database and the
+// target version, which can be set using "@version" suffix on pkgName.
+// If no target version is set, the latest version from remote is used.
+// The commit on older version is assumed to be good.
+//
+// After bisect finished, the package pkgName is restored to their version
+// in database.
+func (env *Env) Bisect(pkgName, testPkg string) (err error) {
+	var logp = `Bisect`
+
+	pkgName, target := parsePkgVersion(pkgName)
+
+	_, pkg := env.GetPackageFromDB(pkgName, "")
+	if pkg == nil || pkg.ImportPath != pkgName {
+		return fmt.Errorf("%s: package %s not found in database", logp,
+			pkgName)
+	}
+
+	_, tpkg := env.GetPackageFromDB(testPkg, "")
+	if tpkg == nil || tpkg.ImportPath != testPkg {
+		return fmt.Errorf("%s: package %s not found in database", logp,
+			testPkg)
+	}
+
+	if pkg.vcsMode != VCSModeGit {
+		return fmt.Errorf(`%s: %w %s`, logp, ErrVCS, pkg.vcsMode)
+	}
+
+	ok, err := env.checkDirty(pkg, logp)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if !ok {
+		env.pkgsSkipped = nil
+		return fmt.Errorf(`%s: %s: %w`, logp, pkg.ImportPath, ErrDirty)
+	}
+
+	// The local changes are restored after the version is restored.
+	defer env.restoreDirty(pkg, logp)
+
+	if len(target) == 0 {
+		err = env.useMirror(pkg)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		err = pkg.FetchLatestVersion()
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		target = pkg.VersionNext
+	}
+
+	good, bad := pkg.Version, target
+
+	isAncestor, err := pkg.gitIsAncestor(bad, good)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if isAncestor {
+		good, bad = bad, good
+	}
+
+	commits, err := pkg.gitRevList(good, bad)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if len(commits) == 0 {
+		env.log.Printf("[ENV] Bisect >>> No commits between %s and %s\n",
+			good, bad)
+		return nil
+	}
+
+	env.log.Printf("[ENV] Bisect %s >>> %d commits between %s and %s\n",
+		pkg.ImportPath, len(commits), good, bad)
+
+	defer func() {
+		env.log.Printf("[ENV] Bisect %s >>> Restoring version to %s\n",
+			pkg.ImportPath, pkg.Version)
+		errRestore := pkg.CheckoutVersion(pkg.Version)
+		if errRestore != nil && err == nil {
+			err = fmt.Errorf(`%s: %w`, logp, errRestore)
+		}
+	}()
+
+	check := func(rev string) (bool, error) {
+		env.log.Printf("\n[ENV] Bisect %s >>> Testing %s on %s\n",
+			tpkg.ImportPath, pkg.ImportPath, rev)
+
+		err := pkg.CheckoutVersion(rev)
+		if err != nil {
+			return false, err
+		}
+		if tpkg.GoBuild(env.path) != nil {
+			return false, nil
+		}
+		return tpkg.GoTest(env.path) == nil, nil
+	}
+
+	idx, err := bisect(commits, check)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	if idx < 0 {
+		env.log.Printf("\n[ENV] Bisect >>> %s pass on %s, no bad commit found.\n",
+			tpkg.ImportPath, bad)
+		return nil
+	}
+
+	env.log.Printf("\n[ENV] Bisect >>> The first bad commit on %s is %s\n",
+		pkg.ImportPath, commits[idx])
+
+	compareURL := env.compareURLs.compareURL(pkg.RemoteURL, good,
+		commits[idx])
+	if len(compareURL) > 0 {
+		env.log.Printf("[ENV] Bisect >>> %s\n", compareURL)
+	}
+
+	return nil
+}
+
+// bisect return the index of first commit that fail the check, using
+// binary search.
+// The commits must be ordered from the oldest one, and the parent of first
+// commit is assumed to be pass the check.
+// It will return -1 if the last commit pass the check.
+// If the check return an error, bisect stop immediately and return the
+// error.
+func bisect(commits []string, check func(rev string) (bool, error)) (int, error) {
+	lo, hi := -1, len(commits)-1
+
+	ok, err := check(commits[hi])
+	if err != nil {
+		return -1, err
+	}
+	if ok {
+		return -1, nil
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		ok, err = check(commits[mid])
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return hi, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestBisect(t *testing.T) {
+	commits := []string{"c1", "c2", "c3", "c4", "c5", "c6"}
+
+	cases := []struct {
+		desc     string
+		firstBad int
+		exp      int
+	}{{
+		desc:     "With all commits pass",
+		firstBad: len(commits),
+		exp:      -1,
+	}, {
+		desc:     "With first commit is bad",
+		firstBad: 0,
+		exp:      0,
+	}, {
+		desc:     "With middle commit is bad",
+		firstBad: 3,
+		exp:      3,
+	}, {
+		desc:     "With last commit is bad",
+		firstBad: 5,
+		exp:      5,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		var nchecks int
+
+		check := func(rev string) (bool, error) {
+			nchecks++
+			for x, commit := range commits {
+				if commit == rev {
+					return x < c.firstBad, nil
+				}
+			}
+			t.Fatalf("unknown revision %s", rev)
+			return false, nil
+		}
+
+		got, err := bisect(commits, check)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, "bisect", c.exp, got)
+
+		if nchecks > 4 {
+			t.Fatalf("bisect: too many checks: %d", nchecks)
+		}
+	}
+
+	errCheckout := errors.New("checkout failed")
+	var nchecks int
+
+	check := func(rev string) (bool, error) {
+		nchecks++
+		if rev == "c3" {
+			return false, errCheckout
+		}
+		return rev < "c3", nil
+	}
+
+	_, err := bisect(commits, check)
+	test.Assert(t, "checkout error", errCheckout, err)
+	test.Assert(t, "stop at first error", 2, nchecks)
+}
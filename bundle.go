@@ -0,0 +1,309 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	libio "github.com/shuLhan/share/lib/io"
+)
+
+const (
+	bundleDir    = "bundles"
+	bundleSuffix = ".bundle"
+)
+
+// Bundle pack the package database and git bundle of each package in
+// database into single archive file.
+// The archive can be used to recreate all packages without network
+// using Unbundle.
+//
+// The archive format is based on the file name suffix: ".tar.zst" for tar
+// compressed with Zstandard, ".tar.gz" or ".tgz" for tar compressed with
+// gzip, or tar otherwise.
+func (env *Env) Bundle(file string) (err error) {
+	var logp = `Bundle`
+
+	tmpDir, err := os.MkdirTemp("", "beku-bundle-")
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dirty := env.dirty
+	env.dirty = true
+	err = env.Save(filepath.Join(tmpDir, DefDBName))
+	env.dirty = dirty
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	for _, pkg := range env.pkgs {
+		env.log.Printf("[ENV] Bundle >>> %s@%s\n", pkg.ImportPath, pkg.Version)
+
+		bundleFile := filepath.Join(tmpDir, bundleDir,
+			pkg.ImportPath+bundleSuffix)
+
+		err = os.MkdirAll(filepath.Dir(bundleFile), 0700)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+
+		err = pkg.Bundle(bundleFile)
+		if err != nil {
+			return fmt.Errorf(`%s: %s: %w`, logp, pkg.ImportPath, err)
+		}
+	}
+
+	err = archiveCreate(env.log, file, tmpDir)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	env.log.Println("[ENV] Bundle >>> finished", file)
+
+	return nil
+}
+
+// Unbundle recreate all packages from archive file created by Bundle.
+// Each package is cloned or fetched from their git bundle, set to the
+// version recorded in the bundled database, and their remote set to the
+// original remote URL.
+// The packages in bundled database replace the current environment packages,
+// and the bundled excluded packages are merged with the current one.
+// The database is saved into the same file as the current database.
+func (env *Env) Unbundle(file string) (err error) {
+	var logp = `Unbundle`
+
+	tmpDir, err := os.MkdirTemp("", "beku-unbundle-")
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = archiveExtract(env.log, file, tmpDir)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	dbFile := env.dbFile
+	excludes := env.pkgsExclude
+
+	env.pkgs = nil
+	env.pkgsExclude = nil
+	env.pkgsMissing = nil
+
+	err = env.Load(filepath.Join(tmpDir, DefDBName))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	env.dbFile = dbFile
+	for _, exclude := range excludes {
+		env.addExclude(exclude)
+	}
+
+	for _, pkg := range env.pkgs {
+		env.log.Printf("[ENV] Unbundle >>> %s@%s\n", pkg.ImportPath, pkg.Version)
+
+		pkg.mirrorURL = filepath.Join(tmpDir, bundleDir,
+			pkg.ImportPath+bundleSuffix)
+
+		if libio.IsDirEmpty(pkg.FullPath) {
+			err = pkg.Install()
+			if err == nil {
+				err = pkg.CheckoutVersion(pkg.Version)
+			}
+		} else {
+			var ok bool
+			ok, err = env.checkDirty(pkg, logp)
+			if ok {
+				err = pkg.Freeze()
+				env.restoreDirty(pkg, logp)
+			}
+		}
+
+		pkg.mirrorURL = ""
+
+		if err != nil {
+			return fmt.Errorf(`%s: %s: %w`, logp, pkg.ImportPath, err)
+		}
+	}
+
+	env.printSkipped(logp)
+
+	env.dirty = true
+
+	env.log.Println("[ENV] Unbundle >>> finished")
+
+	return nil
+}
+
+// archiveCreate create archive file from all files inside directory dir.
+func archiveCreate(log *Logger, file, dir string) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	w, err := archiveCompressor(file, f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(name)
+
+		log.Verbosef("= archiveCreate %s\n", hdr.Name)
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, src)
+		_ = src.Close()
+
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	errClose := f.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(file)
+	}
+
+	return err
+}
+
+// archiveExtract extract all files inside archive into directory dir.
+func archiveExtract(log *Logger, file, dir string) (err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := archiveDecompressor(file, f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archiveExtract: invalid file name %q", hdr.Name)
+		}
+
+		log.Verbosef("= archiveExtract %s\n", hdr.Name)
+
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return err
+		}
+
+		dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(dst, tr)
+		errClose := dst.Close()
+		if err != nil {
+			return err
+		}
+		if errClose != nil {
+			return errClose
+		}
+	}
+
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// archiveCompressor return the writer that compress the archive based on
+// file name suffix.
+func archiveCompressor(file string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(file, ".zst"):
+		return zstd.NewWriter(w)
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".tgz"):
+		return gzip.NewWriter(w), nil
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// archiveDecompressor return the reader that decompress the archive based
+// on file name suffix.
+func archiveDecompressor(file string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(file, ".zst"):
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".tgz"):
+		return gzip.NewReader(r)
+	}
+	return io.NopCloser(r), nil
+}
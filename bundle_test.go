@@ -0,0 +1,97 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestArchive(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		DefDBName:                                "[beku]\n",
+		"bundles/github.com/shuLhan/beku.bundle": "bundle",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(srcDir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []string{
+		"out.tar",
+		"out.tar.gz",
+		"out.tgz",
+		"out.tar.zst",
+	}
+
+	for _, name := range cases {
+		t.Log(name)
+
+		file := filepath.Join(t.TempDir(), name)
+		dstDir := t.TempDir()
+
+		err := archiveCreate(nil, file, srcDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = archiveExtract(nil, file, dstDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, exp := range files {
+			got, err := os.ReadFile(filepath.Join(dstDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.Assert(t, name, exp, string(got))
+		}
+	}
+}
+
+func TestEnvUnbundle(t *testing.T) {
+	srcDir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "bundle.tar")
+
+	err := os.WriteFile(filepath.Join(srcDir, DefDBName),
+		[]byte("[beku]\nexclude = github.com/bundled/excluded\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = archiveCreate(nil, file, srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &Env{
+		dbFile:      "beku.db",
+		pkgsExclude: []string{"github.com/user/excluded"},
+	}
+
+	err = env.Unbundle(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "dbFile", "beku.db", env.dbFile)
+	test.Assert(t, "pkgsExclude", []string{
+		"github.com/bundled/excluded",
+		"github.com/user/excluded",
+	}, env.pkgsExclude)
+}
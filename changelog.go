@@ -0,0 +1,168 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const changelogTypeOther = "other"
+
+// changelogTypes define the order of conventional commit types in
+// changelog report.
+// Commit with type that is not in this list is grouped as "other".
+var changelogTypes = []string{
+	"feat", "fix", "perf", "refactor", "revert", "docs", "test", "build",
+	"ci", "chore", "style", changelogTypeOther,
+}
+
+var (
+	// reConventional match the conventional commit subject,
+	// "<type>[(scope)][!]: <description>".
+	reConventional = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?:\s`)
+
+	// reExportedDecl match the added or removed line in diff that
+	// declare exported function, method, type, variable, or constant.
+	reExportedDecl = regexp.MustCompile(
+		`^[+-](func\s+(\([^)]*\)\s*)?|type\s+|var\s+|const\s+)[A-Z]`)
+)
+
+// changelogCommit contains the summary of single commit in changelog.
+type changelogCommit struct {
+	hash     string
+	subject  string
+	kind     string
+	breaking bool
+	exported bool
+}
+
+// newChangelogCommit create new changelog commit and set the commit type
+// from conventional commit subject.
+func newChangelogCommit(hash, subject string) (c *changelogCommit) {
+	c = &changelogCommit{
+		hash:    hash,
+		subject: subject,
+		kind:    changelogTypeOther,
+	}
+
+	m := reConventional.FindStringSubmatch(subject)
+	if m == nil {
+		return c
+	}
+
+	kind := strings.ToLower(m[1])
+	for _, t := range changelogTypes {
+		if t == kind {
+			c.kind = kind
+			break
+		}
+	}
+	c.breaking = len(m[3]) > 0
+
+	return c
+}
+
+// String return the commit summary as single line in changelog report.
+func (c *changelogCommit) String() string {
+	var buf bytes.Buffer
+
+	hash := c.hash
+	if len(hash) > 7 {
+		hash = hash[:7]
+	}
+
+	fmt.Fprintf(&buf, "  * %s %s", hash, c.subject)
+	if c.breaking {
+		buf.WriteString(" [breaking]")
+	}
+	if c.exported {
+		buf.WriteString(" [exported API]")
+	}
+
+	return buf.String()
+}
+
+// isExportedChange will return true if the diff contains added or removed
+// declaration of exported identifier; otherwise it will return false.
+func isExportedChange(diff []byte) bool {
+	for _, line := range bytes.Split(diff, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("+++")) ||
+			bytes.HasPrefix(line, []byte("---")) {
+			continue
+		}
+		if reExportedDecl.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// changelog write the summary of commits between package version and the
+// next version into buffer, grouped by conventional commit type.
+func (pkg *Package) changelog(buf *bytes.Buffer, compareURL string) (err error) {
+	var commits []*changelogCommit
+
+	if pkg.vcsMode == VCSModeGit {
+		commits, err = pkg.gitChangelog(pkg.Version, pkg.VersionNext)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(buf, "# %s %s...%s\n", pkg.ImportPath, pkg.Version,
+		pkg.VersionNext)
+	if len(compareURL) > 0 {
+		fmt.Fprintf(buf, "\n%s\n", compareURL)
+	}
+
+	for _, kind := range changelogTypes {
+		var n int
+		for _, c := range commits {
+			if c.kind != kind {
+				continue
+			}
+			if n == 0 {
+				fmt.Fprintf(buf, "\n## %s\n\n", kind)
+			}
+			fmt.Fprintln(buf, c.String())
+			n++
+		}
+	}
+
+	buf.WriteString("\n")
+
+	return nil
+}
+
+// writeChangelog write the changelog of all packages that will be updated
+// into file.
+func (env *Env) writeChangelog(file string) (err error) {
+	var buf bytes.Buffer
+
+	for _, pkg := range env.pkgs {
+		if pkg.Version >= pkg.VersionNext {
+			continue
+		}
+
+		compareURL := env.compareURLs.compareURL(pkg.RemoteURL,
+			pkg.Version, pkg.VersionNext)
+
+		err = pkg.changelog(&buf, compareURL)
+		if err != nil {
+			return fmt.Errorf("writeChangelog: %s: %w", pkg.ImportPath, err)
+		}
+	}
+
+	err = os.WriteFile(file, buf.Bytes(), 0600)
+	if err != nil {
+		return fmt.Errorf("writeChangelog: %w", err)
+	}
+
+	return nil
+}
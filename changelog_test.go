@@ -0,0 +1,144 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestNewChangelogCommit(t *testing.T) {
+	cases := []struct {
+		subject     string
+		expKind     string
+		expBreaking bool
+	}{{
+		subject: "feat: add new option",
+		expKind: "feat",
+	}, {
+		subject: "Fix(env): handle empty database",
+		expKind: "fix",
+	}, {
+		subject:     "refactor!: remove deprecated function",
+		expKind:     "refactor",
+		expBreaking: true,
+	}, {
+		subject: "unknown: not a known type",
+		expKind: changelogTypeOther,
+	}, {
+		subject: "Update README",
+		expKind: changelogTypeOther,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.subject)
+
+		got := newChangelogCommit("1234567890", c.subject)
+
+		test.Assert(t, "kind", c.expKind, got.kind)
+		test.Assert(t, "breaking", c.expBreaking, got.breaking)
+	}
+}
+
+func TestIsExportedChange(t *testing.T) {
+	cases := []struct {
+		desc string
+		diff string
+		exp  bool
+	}{{
+		desc: "With exported function",
+		diff: "+++ b/a.go\n+func New() {}\n",
+		exp:  true,
+	}, {
+		desc: "With removed exported method",
+		diff: "--- a/a.go\n-func (pkg *Package) Remove() error {\n",
+		exp:  true,
+	}, {
+		desc: "With exported type",
+		diff: "+type Env struct {\n",
+		exp:  true,
+	}, {
+		desc: "With unexported function",
+		diff: "+func newEnv() {}\n",
+	}, {
+		desc: "With unchanged exported function",
+		diff: " func New() {}\n",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		got := isExportedChange([]byte(c.diff))
+
+		test.Assert(t, "isExportedChange", c.exp, got)
+	}
+}
+
+func TestPackageChangelog(t *testing.T) {
+	dir := t.TempDir()
+
+	testGit(t, dir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	testGit(t, dir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "first")
+	testGit(t, dir, "2018-01-01T00:00:00Z", "tag", "v0.1.0")
+
+	err := os.WriteFile(filepath.Join(dir, "a.go"),
+		[]byte("package a\n\nfunc New() {}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, dir, "2018-01-02T00:00:00Z", "add", "a.go")
+	testGit(t, dir, "2018-01-02T00:00:00Z", "commit", "--quiet", "-m",
+		"feat: add New")
+	testGit(t, dir, "2018-01-03T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "fix: empty fix")
+	testGit(t, dir, "2018-01-04T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "Update README")
+	testGit(t, dir, "2018-01-04T00:00:00Z", "tag", "v0.2.0")
+
+	pkg := &Package{
+		ImportPath:  "example.com/a",
+		FullPath:    dir,
+		Version:     "v0.1.0",
+		VersionNext: "v0.2.0",
+		vcsMode:     VCSModeGit,
+	}
+
+	commits, err := pkg.gitChangelog(pkg.Version, pkg.VersionNext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "len(commits)", 3, len(commits))
+	test.Assert(t, "kind", "feat", commits[0].kind)
+	test.Assert(t, "exported", true, commits[0].exported)
+	test.Assert(t, "kind", "fix", commits[1].kind)
+	test.Assert(t, "exported", false, commits[1].exported)
+	test.Assert(t, "kind", changelogTypeOther, commits[2].kind)
+
+	var buf bytes.Buffer
+
+	err = pkg.changelog(&buf, "https://example.com/compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "# example.com/a v0.1.0...v0.2.0\n" +
+		"\nhttps://example.com/compare\n" +
+		"\n## feat\n\n" +
+		"  * " + commits[0].hash[:7] + " feat: add New [exported API]\n" +
+		"\n## fix\n\n" +
+		"  * " + commits[1].hash[:7] + " fix: empty fix\n" +
+		"\n## other\n\n" +
+		"  * " + commits[2].hash[:7] + " Update README\n" +
+		"\n"
+
+	test.Assert(t, "changelog", exp, buf.String())
+}
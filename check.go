@@ -0,0 +1,107 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"strings"
+
+	libio "github.com/shuLhan/share/lib/io"
+)
+
+// Check compare the packages in "src" directory with the database, without
+// changing anything, and print all differences (drift) found.
+//
+// For each package in database, it will report when the package source is
+// missing, the package HEAD is not at the version in database, the remote
+// URL or branch has changed, the working tree have local changes, or the
+// package have missing dependencies.
+// It will also report the repositories in "src" directory that are not
+// registered in database.
+//
+// If pkgs is not empty, only those packages will be checked and the
+// unregistered repositories are not reported.
+//
+// It will return ErrDrift if at least one drift is found.
+func (env *Env) Check(pkgs []string) (err error) {
+	var ndrift int
+
+	format := fmt.Sprintf("%%-%ds  %%s\n", env.fmtMaxPath)
+
+	for _, pkg := range env.pkgs {
+		if env.IsExcluded(pkg.ImportPath) {
+			continue
+		}
+
+		found := len(pkgs) == 0
+		for x := 0; x < len(pkgs) && !found; x++ {
+			found = pkgs[x] == pkg.ImportPath
+		}
+		if !found {
+			continue
+		}
+
+		drifts, err := pkg.drifts()
+		if err != nil {
+			return fmt.Errorf("Check: %s: %w", pkg.ImportPath, err)
+		}
+
+		for _, drift := range drifts {
+			fmt.Fprintf(env.log.Out(), format, pkg.ImportPath, drift)
+		}
+		ndrift += len(drifts)
+	}
+
+	if len(pkgs) == 0 {
+		env.pkgsUnused = nil
+
+		err = env.GetUnused(env.dirSrc)
+		if err != nil {
+			return fmt.Errorf("Check: %w", err)
+		}
+
+		for _, pkg := range env.pkgsUnused {
+			fmt.Fprintf(env.log.Out(), format, pkg.ImportPath,
+				"not registered in database")
+		}
+		ndrift += len(env.pkgsUnused)
+	}
+
+	if ndrift > 0 {
+		return fmt.Errorf("Check: %d %w", ndrift, ErrDrift)
+	}
+
+	return nil
+}
+
+// drifts return list of differences between package in "src" directory
+// and package in database.
+func (pkg *Package) drifts() (drifts []string, err error) {
+	if libio.IsDirEmpty(pkg.FullPath) {
+		return []string{"source is missing"}, nil
+	}
+
+	if pkg.vcsMode == VCSModeGit {
+		drifts, err = pkg.gitDrifts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := pkg.dirtyStatus()
+	if err != nil {
+		return nil, err
+	}
+	if st.isDirty() {
+		drifts = append(drifts, "working tree has "+st.String())
+	}
+
+	if len(pkg.DepsMissing) > 0 {
+		drifts = append(drifts, "missing dependencies: "+
+			strings.Join(pkg.DepsMissing, ", "))
+	}
+
+	return drifts, nil
+}
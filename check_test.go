@@ -0,0 +1,92 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestPackageDrifts(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+	remoteURL := filepath.Join(filepath.Dir(pkg.FullPath), "remote.git")
+
+	head, err := pkg.gitRevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.RemoteName = gitDefRemoteName
+	pkg.RemoteURL = remoteURL
+	pkg.RemoteBranch = gitDefBranch
+	pkg.Version = head
+
+	got, err := pkg.drifts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "drifts on clean package", []string(nil), got)
+
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "local")
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "remote", "set-url",
+		gitDefRemoteName, "https://example.com/repo")
+
+	newHead, err := pkg.gitRevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.DepsMissing = []string{"example.com/missing"}
+
+	exp := []string{
+		"HEAD is at " + newHead + ", expecting " + head,
+		"remote URL is https://example.com/repo, expecting " + remoteURL,
+		"working tree has 1 local commits",
+		"missing dependencies: example.com/missing",
+	}
+
+	got, err = pkg.drifts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "drifts", exp, got)
+
+	pkg.FullPath = filepath.Join(t.TempDir(), "notexist")
+
+	got, err = pkg.drifts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "drifts on missing package", []string{"source is missing"}, got)
+}
+
+func TestEnvCheck(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+
+	head, err := pkg.gitRevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.RemoteName = gitDefRemoteName
+	pkg.RemoteURL = filepath.Join(filepath.Dir(pkg.FullPath), "remote.git")
+	pkg.Version = head
+
+	env := &Env{
+		pkgs: []*Package{pkg},
+	}
+
+	err = env.Check([]string{pkg.ImportPath})
+	test.Assert(t, "Check on clean package", nil, err)
+
+	pkg.Version = "v1.0.0"
+
+	err = env.Check([]string{pkg.ImportPath})
+	test.Assert(t, "Check on drift package", true, errors.Is(err, ErrDrift))
+}
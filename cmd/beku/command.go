@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -23,38 +24,94 @@ var (
 
 const (
 	flagOperationHelp     = "Show the short usage."
+	flagOperationAudit    = "Match all packages against vulnerabilities in OSV database `path`, a directory or zip file, without network."
+	flagOperationBisect   = "Find the first commit on package that break the build or tests of another package."
+	flagOperationBundle   = "Pack database and all packages into archive `file`."
 	flagOperationDatabase = "Operate on the package database."
 	flagOperationFreeze   = "Install all packages on database."
+	flagOperationMirror   = "Create or update bare mirror of all packages on database into `directory`."
 	flagOperationQuery    = "Query the package database."
 	flagOperationRemove   = "Remove package."
 	flagOperationSync     = "Synchronize package. If no package is given, it will do rescan."
+	flagOperationUnbundle = "Recreate database and all packages from archive `file`."
 	flagOperationVersion  = "Print beku version."
 
-	flagOptionExclude   = "Exclude package from further operation"
-	flagOptionNoConfirm = "No confirmation will be asked on any operation."
-	flagOptionNoDeps    = "Do not install any missing dependencies."
-	flagOptionRecursive = "Remove package including their dependencies."
-	flagOptionSyncInto  = "Download package into `directory`."
-	flagOptionUpdate    = "Update all packages to latest version."
+	flagOptionChangelog      = "Write summary of commits of all packages that will be updated into `file`."
+	flagOptionCheckAPI       = "Compare the exported API of all packages that will be updated, and mark the update that break the API."
+	flagOptionDB             = "Read and write the package database from `file`, instead of \"{prefix}/var/beku/beku.db\"."
+	flagOptionExclude        = "Exclude package from further operation"
+	flagOptionForce          = "Discard local changes on package before changing their version."
+	flagOptionFromMirror     = "Clone new packages from mirror directory, but fetch from their remote URL."
+	flagOptionKeepGoing      = "Continue with the next package if one of package failed to be synced or installed, and report all failed packages at the end."
+	flagOptionMirror         = "Clone and fetch packages from bare repositories in `directory`, without network."
+	flagOptionNoConfirm      = "No confirmation will be asked on any operation."
+	flagOptionNoDeps         = "Do not install any missing dependencies."
+	flagOptionPrefix         = "Manage packages in `directory` instead of GOPATH."
+	flagOptionQueryCheck     = "Check packages on source directory against database, exit with non-zero status if drift found."
+	flagOptionQueryLicense   = "Detect and list license of packages, exit with non-zero status if license is not allowed."
+	flagOptionQueryUpdate    = "List packages that have newer version, and forked packages that have new tags on upstream."
+	flagOptionQuiet          = "Print only errors and the result of query."
+	flagOptionRecursive      = "Remove package including their dependencies."
+	flagOptionStash          = "Save local changes on package into stash or branch before changing their version, and restore them after."
+	flagOptionSyncInto       = "Download package into `directory`."
+	flagOptionTestDependents = "Build and test all packages that depends on the updated packages, and offer rollback if one of them fail."
+	flagOptionUpdate         = "Update all packages to latest version."
+	flagOptionVerbose        = "Print the commands that are executed."
 )
 
 type command struct {
-	op        operation
-	env       *beku.Env
-	pkgs      []string
-	syncInto  string
-	firstTime bool
-	noConfirm bool
-	noDeps    bool
+	op              operation
+	env             *beku.Env
+	pkgs            []string
+	syncInto        string
+	mirrorDir       string
+	mirrorUpdateDir string
+	bundleFile      string
+	bisectPkg       string
+	osvDB           string
+	testPkg         string
+	changelogFile   string
+	prefix          string
+	dbFile          string
+	optValue        *string
+	firstTime       bool
+	checkAPI        bool
+	fromMirror      bool
+	force           bool
+	keepGoing       bool
+	stash           bool
+	testDeps        bool
+	noConfirm       bool
+	noDeps          bool
+	quiet           bool
+	verbose         bool
 }
 
 func (cmd *command) usage() {
 	help := `usage: beku <operation> [...]
 common options:
+	--prefix <directory>
+		` + flagOptionPrefix + `
+	--db <file>
+		` + flagOptionDB + `
+	--mirror <directory>
+		` + flagOptionMirror + `
+	--from-mirror
+		` + flagOptionFromMirror + `
 	--noconfirm
 		` + flagOptionNoConfirm + `
+	--stash
+		` + flagOptionStash + `
+	--force
+		` + flagOptionForce + `
 	-d,--nodeps
 		` + flagOptionNoDeps + `
+	--keep-going
+		` + flagOptionKeepGoing + `
+	--quiet
+		` + flagOptionQuiet + `
+	--verbose
+		` + flagOptionVerbose + `
 operations:
 	beku {-h|--help}
 		` + flagOperationHelp + `
@@ -65,6 +122,21 @@ operations:
 	beku {-B|--freeze}
 		` + flagOperationFreeze + `
 
+	beku {--audit} {--osv-db} <path>
+		` + flagOperationAudit + `
+
+	beku {--bisect} <pkg[@version]> {--test} <pkg>
+		` + flagOperationBisect + `
+
+	beku {--bundle} <file>
+		` + flagOperationBundle + `
+
+	beku {--unbundle} <file>
+		` + flagOperationUnbundle + `
+
+	beku {--mirror-update} <directory>
+		` + flagOperationMirror + `
+
 	beku {-D|--database}
 		` + flagOperationDatabase + `
 
@@ -75,6 +147,16 @@ operations:
 	beku {-Q|--query} [pkg ...]
 		` + flagOperationQuery + `
 
+	options:
+		[-k|--check]
+			` + flagOptionQueryCheck + `
+
+		[-l|--license]
+			` + flagOptionQueryLicense + `
+
+		[-u|--update]
+			` + flagOptionQueryUpdate + `
+
 	beku {-R|--remove} <pkg> [options]
 		` + flagOperationRemove + `
 
@@ -89,6 +171,15 @@ operations:
 		[-u|--update]
 			` + flagOptionUpdate + `
 
+		[--changelog <file>]
+			` + flagOptionChangelog + `
+
+		[--check-api]
+			` + flagOptionCheckAPI + `
+
+		[--test-dependents]
+			` + flagOptionTestDependents + `
+
 		[--into <directory>]
 			` + flagOptionSyncInto + `
 `
@@ -125,6 +216,23 @@ func (cmd *command) parseFreezeFlags(arg string) error {
 	return errInvalidOptions
 }
 
+func (cmd *command) parseQueryFlags(arg string) (operation, error) {
+	if len(arg) == 0 {
+		return opNone, nil
+	}
+
+	switch arg {
+	case "k":
+		return opCheck, nil
+	case "l":
+		return opLicense, nil
+	case "u":
+		return opUpdate, nil
+	}
+
+	return opNone, errInvalidOptions
+}
+
 func (cmd *command) parseSyncFlags(arg string) (operation, error) {
 	if len(arg) == 0 {
 		return opNone, nil
@@ -200,10 +308,11 @@ func (cmd *command) parseShortFlags(arg string) (operation, error) {
 		}
 		op |= opDatabase
 	case 'Q':
-		op = opQuery
-		if len(arg) > 1 {
-			return opNone, errInvalidOptions
+		op, err = cmd.parseQueryFlags(arg[1:])
+		if err != nil {
+			return opNone, err
 		}
+		op |= opQuery
 	case 'S':
 		op, err = cmd.parseSyncFlags(arg[1:])
 		if err != nil {
@@ -232,28 +341,74 @@ func (cmd *command) parseLongFlags(arg string) (op operation, err error) {
 	switch arg {
 	case "help":
 		op = opHelp
+	case "audit":
+		op = opAudit
+	case "bisect":
+		op = opBisect
+		cmd.optValue = &cmd.bisectPkg
+	case "bundle":
+		op = opBundle
+		cmd.optValue = &cmd.bundleFile
+	case "changelog":
+		cmd.optValue = &cmd.changelogFile
+	case "check":
+		op = opCheck
+	case "check-api":
+		cmd.checkAPI = true
 	case "database":
 		op = opDatabase
+	case "db":
+		cmd.optValue = &cmd.dbFile
 	case "exclude":
 		op = opExclude
+	case "force":
+		cmd.force = true
 	case "freeze":
 		op = opFreeze
 	case "into":
 		op = opSyncInto
+	case "keep-going":
+		cmd.keepGoing = true
+	case "license":
+		op = opLicense
+	case "from-mirror":
+		cmd.fromMirror = true
+	case "mirror":
+		cmd.optValue = &cmd.mirrorDir
+	case "mirror-update":
+		op = opMirrorUpdate
+		cmd.optValue = &cmd.mirrorUpdateDir
 	case "noconfirm":
 		cmd.noConfirm = true
 	case "nodeps":
 		cmd.noDeps = true
+	case "osv-db":
+		cmd.optValue = &cmd.osvDB
+	case "prefix":
+		cmd.optValue = &cmd.prefix
 	case "query":
 		op = opQuery
+	case "quiet":
+		cmd.quiet = true
 	case "recursive":
 		op = opRecursive
 	case "remove":
 		op = opRemove
+	case "stash":
+		cmd.stash = true
 	case "sync":
 		op = opSync
+	case "test":
+		cmd.optValue = &cmd.testPkg
+	case "test-dependents":
+		cmd.testDeps = true
+	case "unbundle":
+		op = opUnbundle
+		cmd.optValue = &cmd.bundleFile
 	case "update":
 		op = opUpdate
+	case "verbose":
+		cmd.verbose = true
 	case "version":
 		op = opVersion
 	default:
@@ -276,6 +431,13 @@ func (cmd *command) parseFlags(args []string) (err error) { //nolint: gocognit
 		op operation
 	)
 	for _, arg := range args {
+		// Option that require value consume the next argument.
+		if cmd.optValue != nil {
+			*cmd.optValue = arg
+			cmd.optValue = nil
+			continue
+		}
+
 		fl = 0
 		for y, r := range arg {
 			if fl == 1 {
@@ -316,8 +478,13 @@ func (cmd *command) parseFlags(args []string) (err error) { //nolint: gocognit
 		}
 	}
 
+	if cmd.optValue != nil {
+		return errInvalidOptions
+	}
+
 	switch cmd.op {
-	case opNone, opExclude, opRecursive, opSyncInto, opUpdate:
+	case opNone, opCheck, opExclude, opLicense, opRecursive, opSyncInto,
+		opUpdate:
 		return errInvalidOptions
 	}
 
@@ -325,10 +492,49 @@ func (cmd *command) parseFlags(args []string) (err error) { //nolint: gocognit
 		return errInvalidOptions
 	}
 
+	if cmd.fromMirror && len(cmd.mirrorDir) == 0 {
+		return errInvalidOptions
+	}
+
+	if cmd.force && cmd.stash {
+		return errInvalidOptions
+	}
+
+	if cmd.quiet && cmd.verbose {
+		return errInvalidOptions
+	}
+
+	if len(cmd.changelogFile) > 0 && cmd.op != opSync|opUpdate {
+		return errInvalidOptions
+	}
+
+	if cmd.checkAPI && cmd.op != opSync|opUpdate {
+		return errInvalidOptions
+	}
+
+	if cmd.testDeps && cmd.op&opSync == 0 {
+		return errInvalidOptions
+	}
+
+	if cmd.keepGoing && cmd.op&(opFreeze|opSync) == 0 {
+		return errInvalidOptions
+	}
+
+	if (cmd.op == opBisect) != (len(cmd.testPkg) > 0) {
+		return errInvalidOptions
+	}
+
+	if (cmd.op&opAudit != 0) != (len(cmd.osvDB) > 0) {
+		return errInvalidOptions
+	}
+
 	// Only one operation is allowed.
-	op = cmd.op & (opDatabase | opFreeze | opQuery | opRemove | opSync)
-	if op != opDatabase && op != opFreeze && op != opQuery &&
-		op != opRemove && op != opSync {
+	op = cmd.op & (opAudit | opBisect | opBundle | opDatabase | opFreeze |
+		opMirrorUpdate | opQuery | opRemove | opSync | opUnbundle)
+	if op != opAudit && op != opBisect && op != opBundle &&
+		op != opDatabase && op != opFreeze && op != opMirrorUpdate &&
+		op != opQuery && op != opRemove && op != opSync &&
+		op != opUnbundle {
 		return errMultiOperations
 	}
 
@@ -340,7 +546,17 @@ func (cmd *command) parseFlags(args []string) (err error) { //nolint: gocognit
 	return nil
 }
 
+// loadDatabase load the database from file set by "--db" option, or from
+// default database in prefix directory if "--prefix" option is set;
+// otherwise from "beku.db" in current directory.
 func (cmd *command) loadDatabase() (err error) {
+	if len(cmd.dbFile) > 0 {
+		return cmd.env.Load(cmd.dbFile)
+	}
+	if len(cmd.prefix) > 0 {
+		return cmd.env.Load("")
+	}
+
 	err = cmd.env.Load(beku.DefDBName)
 	if err == nil {
 		return
@@ -349,7 +565,19 @@ func (cmd *command) loadDatabase() (err error) {
 	return
 }
 
-func (cmd *command) sync() (err error) {
+// options return the environment options from command flags.
+func (cmd *command) options() (opts []beku.Option) {
+	opts = append(opts, beku.WithNoDeps(cmd.noDeps))
+	if len(cmd.prefix) > 0 {
+		opts = append(opts, beku.WithPrefix(cmd.prefix))
+	}
+	if len(cmd.dbFile) > 0 {
+		opts = append(opts, beku.WithDatabase(cmd.dbFile))
+	}
+	return opts
+}
+
+func (cmd *command) sync(ctx context.Context) (err error) {
 	if len(cmd.pkgs) > 1 && len(cmd.syncInto) > 0 {
 		return errInvalidOptions
 	}
@@ -357,7 +585,7 @@ func (cmd *command) sync() (err error) {
 	var ok bool
 
 	if cmd.firstTime {
-		ok, err = cmd.env.Rescan(true)
+		ok, err = cmd.env.RescanContext(ctx, true)
 		if !ok || err != nil {
 			return
 		}
@@ -367,15 +595,15 @@ func (cmd *command) sync() (err error) {
 	case 0:
 		if cmd.op&opUpdate == 0 {
 			if !cmd.firstTime {
-				_, err = cmd.env.Rescan(false)
+				_, err = cmd.env.RescanContext(ctx, false)
 			}
 		} else {
-			err = cmd.env.SyncAll()
+			err = cmd.env.SyncAllContext(ctx)
 		}
 	case 1:
-		err = cmd.env.Sync(cmd.pkgs[0], cmd.syncInto)
+		err = cmd.env.SyncContext(ctx, cmd.pkgs[0], cmd.syncInto)
 	default:
-		err = cmd.env.SyncMany(cmd.pkgs)
+		err = cmd.env.SyncManyContext(ctx, cmd.pkgs)
 	}
 
 	return
@@ -398,7 +626,7 @@ func newCommand() (cmd *command, err error) {
 		os.Exit(0)
 	}
 
-	cmd.env, err = beku.NewEnvironment(cmd.noDeps)
+	cmd.env, err = beku.NewEnvironment(cmd.options()...)
 	if err != nil {
 		return
 	}
@@ -409,6 +637,8 @@ func newCommand() (cmd *command, err error) {
 			if cmd.op&opSync > 0 {
 				cmd.firstTime = true
 				err = nil
+			} else if cmd.op == opUnbundle {
+				err = nil
 			} else {
 				err = errNoDB
 			}
@@ -7,8 +7,10 @@ package main
 import (
 	"go/build"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/shuLhan/beku"
 	"github.com/shuLhan/share/lib/test"
 )
 
@@ -123,6 +125,139 @@ func TestParseFlags(t *testing.T) {
 		expCmd: &command{
 			op: opQuery,
 		},
+	}, {
+		args: []string{"-Qu", "A"},
+		expCmd: &command{
+			op:   opQuery | opUpdate,
+			pkgs: []string{"A"},
+		},
+	}, {
+		args: []string{"--query", "--update"},
+		expCmd: &command{
+			op: opQuery | opUpdate,
+		},
+	}, {
+		args: []string{"-Su", "--changelog", "changes.txt"},
+		expCmd: &command{
+			op:            opSync | opUpdate,
+			changelogFile: "changes.txt",
+		},
+	}, {
+		args:   []string{"-S", "A", "--changelog", "changes.txt"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Su", "--check-api"},
+		expCmd: &command{
+			op:       opSync | opUpdate,
+			checkAPI: true,
+		},
+	}, {
+		args:   []string{"-Q", "--check-api"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Su", "--test-dependents"},
+		expCmd: &command{
+			op:       opSync | opUpdate,
+			testDeps: true,
+		},
+	}, {
+		args:   []string{"-Q", "--test-dependents"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"--bisect", "A@v1.0.0", "--test", "B"},
+		expCmd: &command{
+			op:        opBisect,
+			bisectPkg: "A@v1.0.0",
+			testPkg:   "B",
+		},
+	}, {
+		args:   []string{"--bisect", "A"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args:   []string{"-Q", "--test", "B"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-B", "--stash"},
+		expCmd: &command{
+			op:    opFreeze,
+			stash: true,
+		},
+	}, {
+		args: []string{"-Su", "--force"},
+		expCmd: &command{
+			op:    opSync | opUpdate,
+			force: true,
+		},
+	}, {
+		args:   []string{"-Su", "--force", "--stash"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Q", "--quiet"},
+		expCmd: &command{
+			op:    opQuery,
+			quiet: true,
+		},
+	}, {
+		args:   []string{"-Q", "--quiet", "--verbose"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-S", "A", "B", "--keep-going"},
+		expCmd: &command{
+			op:        opSync,
+			pkgs:      []string{"A", "B"},
+			keepGoing: true,
+		},
+	}, {
+		args: []string{"-B", "--keep-going"},
+		expCmd: &command{
+			op:        opFreeze,
+			keepGoing: true,
+		},
+	}, {
+		args:   []string{"-Q", "--keep-going"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Q", "--prefix", "/srv/go", "--db", "/srv/beku.db"},
+		expCmd: &command{
+			op:     opQuery,
+			prefix: "/srv/go",
+			dbFile: "/srv/beku.db",
+		},
+	}, {
+		args:   []string{"-Q", "--db"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Qk"},
+		expCmd: &command{
+			op: opQuery | opCheck,
+		},
+	}, {
+		args:   []string{"--check"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-Ql", "A"},
+		expCmd: &command{
+			op:   opQuery | opLicense,
+			pkgs: []string{"A"},
+		},
+	}, {
+		args:   []string{"--license"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"--audit", "--osv-db", "osv.zip"},
+		expCmd: &command{
+			op:    opAudit,
+			osvDB: "osv.zip",
+		},
+	}, {
+		args:   []string{"--audit"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args:   []string{"-Q", "--osv-db", "osv.zip"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args:   []string{"--audit", "-B", "--osv-db", "osv.zip"},
+		expErr: errMultiOperations.Error(),
 	}, {
 		args: []string{"-Q", "-h"},
 		expCmd: &command{
@@ -241,6 +376,66 @@ func TestParseFlags(t *testing.T) {
 			noConfirm: true,
 			pkgs:      []string{"A"},
 		},
+	}, {
+		args:   []string{"-S", "A", "--mirror"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args:   []string{"--mirror", "/srv/git"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"--mirror", "/srv/git", "-S", "A"},
+		expCmd: &command{
+			op:        opSync,
+			pkgs:      []string{"A"},
+			mirrorDir: "/srv/git",
+		},
+	}, {
+		args: []string{"-Su", "--mirror", "/srv/git"},
+		expCmd: &command{
+			op:        opSync | opUpdate,
+			mirrorDir: "/srv/git",
+		},
+	}, {
+		args:   []string{"-S", "A", "--from-mirror"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"-S", "A", "--from-mirror", "--mirror", "/srv/git"},
+		expCmd: &command{
+			op:         opSync,
+			pkgs:       []string{"A"},
+			mirrorDir:  "/srv/git",
+			fromMirror: true,
+		},
+	}, {
+		args:   []string{"--mirror-update"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"--mirror-update", "/srv/git"},
+		expCmd: &command{
+			op:              opMirrorUpdate,
+			mirrorUpdateDir: "/srv/git",
+		},
+	}, {
+		args:   []string{"--mirror-update", "/srv/git", "-S"},
+		expErr: errMultiOperations.Error(),
+	}, {
+		args:   []string{"--bundle"},
+		expErr: errInvalidOptions.Error(),
+	}, {
+		args: []string{"--bundle", "out.tar.zst"},
+		expCmd: &command{
+			op:         opBundle,
+			bundleFile: "out.tar.zst",
+		},
+	}, {
+		args: []string{"--unbundle", "in.tar.zst"},
+		expCmd: &command{
+			op:         opUnbundle,
+			bundleFile: "in.tar.zst",
+		},
+	}, {
+		args:   []string{"--unbundle", "in.tar.zst", "-B"},
+		expErr: errMultiOperations.Error(),
 	}, {
 		args:   []string{"-Rx", "A"},
 		expErr: errInvalidOptions.Error(),
@@ -265,6 +460,18 @@ func TestParseFlags(t *testing.T) {
 }
 
 func TestNewCommand(t *testing.T) {
+	prefix := t.TempDir()
+	dbFile := filepath.Join(prefix, "var", "beku", beku.DefDBName)
+
+	err := os.MkdirAll(filepath.Dir(dbFile), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dbFile, []byte("[beku]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	cases := []struct {
 		desc   string
 		gopath string
@@ -304,6 +511,26 @@ func TestNewCommand(t *testing.T) {
 			firstTime: false,
 		},
 		expErr: errNoDB.Error(),
+	}, {
+		desc: "With remove operation and database in custom prefix",
+		args: []string{
+			"beku", "-R", "A", "--prefix", prefix,
+		},
+		expCmd: &command{
+			op:   opRemove,
+			pkgs: []string{"A"},
+		},
+	}, {
+		desc: "With sync operation and custom prefix and database",
+		args: []string{
+			"beku", "-S", "A", "--prefix", "/tmp", "--db",
+			"/tmp/notexist/beku.db",
+		},
+		expCmd: &command{
+			op:        opSync,
+			pkgs:      []string{"A"},
+			firstTime: true,
+		},
 	}}
 
 	for _, c := range cases {
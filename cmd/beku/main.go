@@ -11,8 +11,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+
+	"github.com/shuLhan/beku"
 )
 
 const (
@@ -35,30 +40,83 @@ func main() {
 	}
 
 	cmd.env.NoConfirm = cmd.noConfirm
+	cmd.env.MirrorDir = cmd.mirrorDir
+	cmd.env.FromMirror = cmd.fromMirror
+	cmd.env.ChangelogFile = cmd.changelogFile
+	cmd.env.CheckAPI = cmd.checkAPI
+	cmd.env.TestDependents = cmd.testDeps
+	cmd.env.KeepGoing = cmd.keepGoing
+
+	switch {
+	case cmd.quiet:
+		cmd.env.SetLogLevel(beku.LogQuiet)
+	case cmd.verbose:
+		cmd.env.SetLogLevel(beku.LogVerbose)
+	}
+
+	switch {
+	case cmd.force:
+		cmd.env.DirtyMode = beku.DirtyForce
+	case cmd.stash:
+		cmd.env.DirtyMode = beku.DirtyStash
+	}
+
+	// Interrupting the program stop the operation between packages, and
+	// the packages that has been synced are saved into database.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	switch cmd.op {
+	case opAudit:
+		err = cmd.env.Audit(cmd.osvDB)
+	case opBisect:
+		err = cmd.env.Bisect(cmd.bisectPkg, cmd.testPkg)
+	case opBundle:
+		err = cmd.env.Bundle(cmd.bundleFile)
 	case opDatabase | opExclude:
 		cmd.env.Exclude(cmd.pkgs)
 	case opFreeze:
-		err = cmd.env.Freeze()
+		err = cmd.env.FreezeContext(ctx)
+	case opMirrorUpdate:
+		err = cmd.env.MirrorUpdateContext(ctx, cmd.mirrorUpdateDir)
 	case opQuery:
 		cmd.env.Query(cmd.pkgs)
+	case opQuery | opCheck:
+		err = cmd.env.Check(cmd.pkgs)
+	case opQuery | opLicense:
+		err = cmd.env.QueryLicense(cmd.pkgs)
+		if err != nil {
+			// Save the detected licenses even if some of them
+			// are not allowed.
+			_ = cmd.env.Save("")
+		}
+	case opQuery | opUpdate:
+		err = cmd.env.QueryUpdate(cmd.pkgs)
 	case opRemove:
 		err = cmd.env.Remove(cmd.pkgs[0], false)
 	case opRemove | opRecursive:
 		err = cmd.env.Remove(cmd.pkgs[0], true)
 	case opSync:
-		err = cmd.sync()
+		err = cmd.sync(ctx)
 	case opSync | opSyncInto:
-		err = cmd.sync()
+		err = cmd.sync(ctx)
 	case opSync | opUpdate:
-		err = cmd.sync()
+		err = cmd.sync(ctx)
+	case opUnbundle:
+		err = cmd.env.Unbundle(cmd.bundleFile)
 	default:
 		fmt.Fprintln(os.Stderr, errInvalidOptions)
 		os.Exit(1)
 	}
 
 	if err != nil {
+		// Save the packages that has been synced before the
+		// operation is interrupted, or when some of packages failed
+		// but the operation continue with the rest of them.
+		var pkgErrs beku.PackageErrors
+		if ctx.Err() != nil || cmd.keepGoing || errors.As(err, &pkgErrs) {
+			_ = cmd.env.Save("")
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
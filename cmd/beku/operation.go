@@ -10,14 +10,21 @@ const opNone operation = 0
 
 const (
 	opHelp operation = 1 << iota
+	opAudit
+	opBisect
+	opBundle
+	opCheck
 	opDatabase
 	opExclude
 	opFreeze
+	opLicense
+	opMirrorUpdate
 	opQuery
 	opRecursive
 	opRemove
 	opSync
 	opSyncInto
+	opUnbundle
 	opUpdate
 	opVersion
 )
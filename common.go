@@ -14,48 +14,108 @@ import (
 // given remote URL. Remote URL can be in git format
 // ("git@github.com:<username>/<reponame>") or in HTTP format.
 //
-// On package that hosted on Github, the compare URL format is,
+// On package that hosted on Github, Gitea, or Forgejo (for example,
+// codeberg.org), the compare URL format is,
 //
-//	https://github.com/<username>/<reponame>/compare/<old-version>...<new-version>
+//	https://<host>/<username>/<reponame>/compare/<old-version>...<new-version>
+//
+// On package that hosted on GitLab, the compare URL format is,
+//
+//	https://<host>/<path>/-/compare/<old-version>...<new-version>
+//
+// On package that hosted on Bitbucket, the compare URL format is,
+//
+//	https://bitbucket.org/<username>/<reponame>/branches/compare/<new-version>%0D<old-version>
+//
+// On package that hosted on SourceHut, the URL is the log started from new
+// version,
+//
+//	https://git.sr.ht/~<username>/<reponame>/log/<new-version>
+//
+// On package that hosted on go.googlesource.com, the compare URL format is,
+//
+//	https://go.googlesource.com/<reponame>/+log/<old-version>..<new-version>
+//
+// If the host is unknown, it will return empty string.
 func GetCompareURL(remoteURL, oldVer, newVer string) (url string) {
-	if len(remoteURL) == 0 {
+	host, path := parseRemoteURL(remoteURL)
+	if len(host) == 0 || len(path) == 0 {
 		return
 	}
 
-	remoteURL = strings.TrimPrefix(remoteURL, "git@")
-	remoteURL = strings.TrimPrefix(remoteURL, "https://")
-	remoteURL = strings.TrimPrefix(remoteURL, "www.")
-	remoteURL = strings.TrimSuffix(remoteURL, ".git")
+	names := strings.Split(path, "/")
+	user := names[0]
+	repo := names[len(names)-1]
 
-	var host, user, repo string
+	switch {
+	case host == "go.googlesource.com":
+		url = fmt.Sprintf("https://%s/%s/+log/%s..%s", host, path,
+			oldVer, newVer)
+	case len(names) < 2:
+		return
+	case host == "github.com", host == "codeberg.org", host == "gitea.com",
+		strings.HasPrefix(host, "gitea."),
+		strings.HasPrefix(host, "forgejo."):
+		url = fmt.Sprintf("https://%s/%s/%s/compare/%s...%s", host,
+			user, repo, oldVer, newVer)
+	case host == "gitlab.com", strings.HasPrefix(host, "gitlab."):
+		url = fmt.Sprintf("https://%s/%s/-/compare/%s...%s", host,
+			path, oldVer, newVer)
+	case host == "bitbucket.org":
+		url = fmt.Sprintf("https://%s/%s/%s/branches/compare/%s%%0D%s",
+			host, user, repo, newVer, oldVer)
+	case host == "git.sr.ht":
+		url = fmt.Sprintf("https://%s/%s/%s/log/%s", host, user, repo,
+			newVer)
+	case host == "golang.org":
+		url = fmt.Sprintf("https://github.com/golang/%s/compare/%s...%s",
+			repo, oldVer, newVer)
+	}
 
-	colIdx := strings.IndexByte(remoteURL, ':')
-	if colIdx > 0 {
-		names := strings.Split(remoteURL[colIdx+1:], "/")
+	return url
+}
 
-		host = remoteURL[0:colIdx]
-		user = names[0]
-		repo = names[len(names)-1]
+// parseRemoteURL return the host name and repository path from remote URL.
+// Remote URL can be in SCP-like format ("git@<host>:<path>") or in URL
+// format ("<scheme>://[user@]<host>[:port]/<path>").
+// The "www." prefix on host name and the ".git" suffix on path are
+// removed.
+func parseRemoteURL(remoteURL string) (host, path string) {
+	idx := strings.Index(remoteURL, "://")
+	if idx >= 0 {
+		remoteURL = remoteURL[idx+3:]
+		idx = strings.IndexByte(remoteURL, '/')
+		if idx < 0 {
+			return "", ""
+		}
+		host = remoteURL[:idx]
+		path = remoteURL[idx+1:]
+
+		if idx = strings.IndexByte(host, ':'); idx >= 0 {
+			host = host[:idx]
+		}
 	} else {
-		names := strings.Split(remoteURL, "/")
-		if len(names) < 3 {
-			return
+		idx = strings.IndexByte(remoteURL, ':')
+		slash := strings.IndexByte(remoteURL, '/')
+		if idx < 0 || (slash >= 0 && slash < idx) {
+			idx = slash
+		}
+		if idx < 0 {
+			return "", ""
 		}
-		host = names[0]
-		user = names[1]
-		repo = names[len(names)-1]
+		host = remoteURL[:idx]
+		path = remoteURL[idx+1:]
 	}
 
-	switch host {
-	case "github.com":
-		url = fmt.Sprintf("https://%s/%s/%s/compare/%s...%s", host,
-			user, repo, oldVer, newVer)
-	case "golang.org":
-		url = fmt.Sprintf("https://github.com/golang/%s/compare/%s...%s",
-			repo, oldVer, newVer)
+	if idx = strings.LastIndexByte(host, '@'); idx >= 0 {
+		host = host[idx+1:]
 	}
+	host = strings.TrimPrefix(host, "www.")
 
-	return url
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+
+	return host, path
 }
 
 // IsIgnoredDir will return true if directory start with "_" or ".", or
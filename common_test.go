@@ -37,6 +37,42 @@ func TestGetCompareURL(t *testing.T) {
 		oldVer:    "A",
 		newVer:    "B",
 		exp:       "https://github.com/golang/net/compare/A...B",
+	}, {
+		desc:      "With GitLab",
+		remoteURL: "git@gitlab.com:group/subgroup/repo.git",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://gitlab.com/group/subgroup/repo/-/compare/A...B",
+	}, {
+		desc:      "With self-hosted GitLab",
+		remoteURL: "ssh://git@gitlab.example.com:2222/user/repo.git",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://gitlab.example.com/user/repo/-/compare/A...B",
+	}, {
+		desc:      "With Bitbucket",
+		remoteURL: "https://bitbucket.org/user/repo",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://bitbucket.org/user/repo/branches/compare/B%0DA",
+	}, {
+		desc:      "With Forgejo",
+		remoteURL: "https://codeberg.org/user/repo.git",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://codeberg.org/user/repo/compare/A...B",
+	}, {
+		desc:      "With SourceHut",
+		remoteURL: "https://git.sr.ht/~user/repo",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://git.sr.ht/~user/repo/log/B",
+	}, {
+		desc:      "With go.googlesource.com",
+		remoteURL: "https://go.googlesource.com/text",
+		oldVer:    "A",
+		newVer:    "B",
+		exp:       "https://go.googlesource.com/text/+log/A..B",
 	}, {
 		desc:      "With unknown hostname",
 		remoteURL: "https://gopkg.in/yaml.v2",
@@ -53,6 +89,44 @@ func TestGetCompareURL(t *testing.T) {
 	}
 }
 
+func TestParseRemoteURL(t *testing.T) {
+	cases := []struct {
+		remoteURL string
+		expHost   string
+		expPath   string
+	}{{
+		remoteURL: "git@github.com:shuLhan/beku.git",
+		expHost:   "github.com",
+		expPath:   "shuLhan/beku",
+	}, {
+		remoteURL: "https://www.github.com/shuLhan/beku/",
+		expHost:   "github.com",
+		expPath:   "shuLhan/beku",
+	}, {
+		remoteURL: "ssh://git@git.internal:2222/group/repo.git",
+		expHost:   "git.internal",
+		expPath:   "group/repo",
+	}, {
+		remoteURL: "github.com/shuLhan/beku",
+		expHost:   "github.com",
+		expPath:   "shuLhan/beku",
+	}, {
+		remoteURL: "https://github.com",
+	}, {
+		remoteURL: "/path/to/repo",
+		expPath:   "path/to/repo",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.remoteURL)
+
+		host, path := parseRemoteURL(c.remoteURL)
+
+		test.Assert(t, "host", c.expHost, host)
+		test.Assert(t, "path", c.expPath, path)
+	}
+}
+
 func TestIsIgnoredDir(t *testing.T) {
 	cases := []struct {
 		name string
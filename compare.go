@@ -0,0 +1,89 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List of placeholders in compare URL template.
+const (
+	compareVarHost = "{host}"
+	compareVarPath = "{path}"
+	compareVarOld  = "{old}"
+	compareVarNew  = "{new}"
+)
+
+// compareURLTemplate define user defined URL to compare two versions of
+// package for specific host.
+//
+// The template is defined in "[beku]" section of database with the
+// following format,
+//
+//	compare-url = <host> => <URL template>
+//
+// The URL template may contains the placeholders "{host}", "{path}" (the
+// repository path without ".git" suffix), "{old}" (the current version),
+// and "{new}" (the new version).
+// For example,
+//
+//	compare-url = git.internal => https://{host}/{path}/compare/{old}..{new}
+type compareURLTemplate struct {
+	host   string
+	format string
+}
+
+// parseCompareURLTemplate parse the template from string
+// "<host> => <URL template>".
+func parseCompareURLTemplate(v string) (tmpl *compareURLTemplate, err error) {
+	idx := strings.Index(v, sepRewrite)
+	if idx < 0 {
+		return nil, fmt.Errorf("parseCompareURLTemplate: missing %q in %q",
+			sepRewrite, v)
+	}
+
+	tmpl = &compareURLTemplate{
+		host:   strings.TrimSpace(v[:idx]),
+		format: strings.TrimSpace(v[idx+len(sepRewrite):]),
+	}
+	if len(tmpl.host) == 0 || len(tmpl.format) == 0 {
+		return nil, fmt.Errorf("parseCompareURLTemplate: empty host or URL in %q", v)
+	}
+
+	return tmpl, nil
+}
+
+// String return the template in the same format as in database.
+func (tmpl *compareURLTemplate) String() string {
+	return tmpl.host + " " + sepRewrite + " " + tmpl.format
+}
+
+// compareURLTemplates contains list of user defined compare URL.
+type compareURLTemplates []*compareURLTemplate
+
+// compareURL return the URL that compare two versions of package from
+// remote URL using the template that match with remote host.
+// If no template match, it will fallback to GetCompareURL.
+func (tmpls compareURLTemplates) compareURL(remoteURL, oldVer, newVer string) string {
+	host, path := parseRemoteURL(remoteURL)
+
+	for _, tmpl := range tmpls {
+		if tmpl.host != host {
+			continue
+		}
+
+		r := strings.NewReplacer(
+			compareVarHost, host,
+			compareVarPath, path,
+			compareVarOld, oldVer,
+			compareVarNew, newVer,
+		)
+
+		return r.Replace(tmpl.format)
+	}
+
+	return GetCompareURL(remoteURL, oldVer, newVer)
+}
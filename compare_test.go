@@ -0,0 +1,71 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestParseCompareURLTemplate(t *testing.T) {
+	cases := []struct {
+		v      string
+		exp    *compareURLTemplate
+		expErr string
+	}{{
+		v:      "git.internal",
+		expErr: `parseCompareURLTemplate: missing "=>" in "git.internal"`,
+	}, {
+		v:      "git.internal =>",
+		expErr: `parseCompareURLTemplate: empty host or URL in "git.internal =>"`,
+	}, {
+		v: " git.internal => https://{host}/{path}/compare/{old}..{new} ",
+		exp: &compareURLTemplate{
+			host:   "git.internal",
+			format: "https://{host}/{path}/compare/{old}..{new}",
+		},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.v)
+
+		got, err := parseCompareURLTemplate(c.v)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "compareURLTemplate", c.exp, got)
+	}
+}
+
+func TestCompareURLTemplates(t *testing.T) {
+	tmpls := compareURLTemplates{{
+		host:   "git.internal",
+		format: "https://{host}/{path}/-/compare/{old}...{new}",
+	}}
+
+	cases := []struct {
+		remoteURL string
+		exp       string
+	}{{
+		remoteURL: "git@git.internal:group/repo.git",
+		exp:       "https://git.internal/group/repo/-/compare/A...B",
+	}, {
+		remoteURL: "https://github.com/shuLhan/beku",
+		exp:       "https://github.com/shuLhan/beku/compare/A...B",
+	}, {
+		remoteURL: "https://git.example.com/user/repo",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.remoteURL)
+
+		got := tmpls.compareURL(c.remoteURL, "A", "B")
+
+		test.Assert(t, "compareURL", c.exp, got)
+	}
+}
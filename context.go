@@ -0,0 +1,72 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"context"
+	"fmt"
+)
+
+// withContext bind the environment and all packages to context ctx, so all
+// git and go commands are killed when the context is done.
+// The returned function restore the previous context, and must be called
+// after the operation finished.
+func (env *Env) withContext(ctx context.Context) (restore func()) {
+	prevCtx := env.ctx
+
+	env.setContext(ctx)
+
+	return func() {
+		env.setContext(prevCtx)
+	}
+}
+
+// setContext set the context of environment and all packages.
+func (env *Env) setContext(ctx context.Context) {
+	env.ctx = ctx
+	for _, pkg := range env.pkgs {
+		pkg.ctx = ctx
+	}
+}
+
+// context return the environment context, or background context if its not
+// set.
+func (env *Env) context() context.Context {
+	if env.ctx == nil {
+		return context.Background()
+	}
+	return env.ctx
+}
+
+// canceled return an error that wrap the context error, if the environment
+// context has been canceled or its deadline exceeded; otherwise it will
+// return nil.
+// The operation op is used as prefix of the error.
+func (env *Env) canceled(op string) error {
+	if env.ctx == nil {
+		return nil
+	}
+	err := env.ctx.Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// contextErr replace the error err with the context error, if err is not
+// nil and the environment context is done.
+// The error from command that has been killed does not tell why it is
+// killed, so caller can check it using errors.Is with context.Canceled or
+// context.DeadlineExceeded.
+func (env *Env) contextErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	errCtx := env.canceled(op)
+	if errCtx != nil {
+		return errCtx
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestPackageContext(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+
+	_, err := pkg.gitLatestCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pkg.ctx = ctx
+
+	_, err = pkg.gitLatestCommit()
+	test.Assert(t, "gitLatestCommit canceled", true, err != nil)
+
+	err = pkg.gitFetchAll()
+	test.Assert(t, "gitFetchAll canceled", true, err != nil)
+
+	_, err = pkg.GetRecursiveImportsContext(ctx, nil)
+	test.Assert(t, "GetRecursiveImportsContext canceled", true, err != nil)
+
+	err = pkg.GoClean()
+	test.Assert(t, "GoClean canceled", true, err != nil)
+
+	err = mirrorUpdate(ctx, nil, pkg.FullPath,
+		filepath.Join(t.TempDir(), "repo.git"))
+	test.Assert(t, "mirrorUpdate canceled", true, err != nil)
+
+	// The source is still removed after the context is canceled.
+	err = pkg.Remove()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(pkg.FullPath)
+	test.Assert(t, "Remove canceled", true, os.IsNotExist(err))
+}
+
+func TestEnvSyncAllContext(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+	pkg.RemoteName = gitDefRemoteName
+	pkg.RemoteURL = "https://example.com/repo"
+
+	env := &Env{
+		pkgs:      []*Package{pkg},
+		NoConfirm: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := env.SyncAllContext(ctx)
+
+	test.Assert(t, "error is canceled", true, errors.Is(err, context.Canceled))
+	test.Assert(t, "dirty", false, env.dirty)
+	test.Assert(t, "env context is restored", nil, env.ctx)
+	test.Assert(t, "package context is restored", nil, pkg.ctx)
+}
+
+func TestEnvFreezeContext(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+
+	env := &Env{
+		pkgs: []*Package{pkg},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	err := env.FreezeContext(ctx)
+
+	test.Assert(t, "error is deadline exceeded", true,
+		errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestEnvContextErr(t *testing.T) {
+	env := &Env{}
+	errCmd := errors.New("signal: killed")
+
+	test.Assert(t, "without context", errCmd, env.contextErr("Op", errCmd))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	restore := env.withContext(ctx)
+
+	test.Assert(t, "context not done", errCmd, env.contextErr("Op", errCmd))
+	test.Assert(t, "nil error", nil, env.contextErr("Op", nil))
+
+	cancel()
+
+	err := env.contextErr("Op", errCmd)
+	test.Assert(t, "context canceled", "Op: context canceled", err.Error())
+
+	restore()
+
+	test.Assert(t, "restored", nil, env.ctx)
+}
+
+func TestEnvMirrorUpdateContext(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+	pkg.RemoteURL = pkg.FullPath
+
+	env := &Env{
+		pkgs: []*Package{pkg},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := env.MirrorUpdateContext(ctx, t.TempDir())
+
+	test.Assert(t, "error is canceled", true, errors.Is(err, context.Canceled))
+	test.Assert(t, "env context is restored", nil, env.ctx)
+}
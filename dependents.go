@@ -0,0 +1,195 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+const (
+	msgRollback = "Rollback updated packages?"
+
+	resultFail = "fail"
+	resultPass = "pass"
+	resultSkip = "skip"
+)
+
+// dependentResult contains the result of building and testing the package
+// that depends on updated packages.
+type dependentResult struct {
+	pkg   *Package
+	build string
+	test  string
+}
+
+// dependents return all packages that require the updated packages,
+// directly or indirectly, sorted by their dependency order: package is
+// placed after all of their dependencies.
+func (env *Env) dependents(updated map[string]string) (pkgs []*Package) {
+	set := make(map[string]*Package)
+
+	var queue []string
+	for importPath := range updated {
+		queue = append(queue, importPath)
+	}
+
+	for len(queue) > 0 {
+		_, pkg := env.GetPackageFromDB(queue[0], "")
+		queue = queue[1:]
+		if pkg == nil {
+			continue
+		}
+		for _, reqBy := range pkg.RequiredBy {
+			if _, ok := set[reqBy]; ok {
+				continue
+			}
+			_, reqPkg := env.GetPackageFromDB(reqBy, "")
+			if reqPkg == nil {
+				continue
+			}
+			set[reqBy] = reqPkg
+			queue = append(queue, reqBy)
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for importPath := range set {
+		names = append(names, importPath)
+	}
+	sort.Strings(names)
+
+	visited := make(map[string]bool, len(set))
+
+	var visit func(pkg *Package)
+	visit = func(pkg *Package) {
+		if visited[pkg.ImportPath] {
+			return
+		}
+		visited[pkg.ImportPath] = true
+		for _, dep := range pkg.Deps {
+			if depPkg, ok := set[dep]; ok {
+				visit(depPkg)
+			}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	for _, importPath := range names {
+		visit(set[importPath])
+	}
+
+	return pkgs
+}
+
+// testDependents build and test all packages that depends on the updated
+// packages, print the result, and ask user to rollback the updated packages
+// if one of them fail.
+//
+// The updated map the import path of updated package to their version
+// before update.
+func (env *Env) testDependents(updated map[string]string) (err error) {
+	pkgs := env.dependents(updated)
+	if len(pkgs) == 0 {
+		env.log.Println("[ENV] testDependents >>> No dependents found.")
+		return nil
+	}
+
+	var (
+		results = make([]*dependentResult, 0, len(pkgs))
+		failed  bool
+	)
+
+	for _, pkg := range pkgs {
+		env.log.Printf("\n[ENV] testDependents >>> %s\n", pkg.ImportPath)
+
+		res := &dependentResult{
+			pkg:   pkg,
+			build: resultPass,
+			test:  resultPass,
+		}
+
+		err = pkg.GoBuild(env.path)
+		if err != nil {
+			res.build = resultFail
+			res.test = resultSkip
+			failed = true
+		} else {
+			err = pkg.GoTest(env.path)
+			if err != nil {
+				res.test = resultFail
+				failed = true
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	env.log.Println(env.formatDependentResults(results))
+
+	if !failed {
+		return nil
+	}
+
+	if env.NoConfirm {
+		return nil
+	}
+
+	ok := env.confirm(msgRollback, false)
+	if !ok {
+		return nil
+	}
+
+	return env.rollback(updated)
+}
+
+// formatDependentResults return the result of testing dependents as table.
+func (env *Env) formatDependentResults(results []*dependentResult) string {
+	var buf bytes.Buffer
+
+	format := fmt.Sprintf("%%-%ds  %%-5s  %%-5s\n", env.fmtMaxPath)
+
+	fmt.Fprintf(&buf, "\n[ENV] testDependents >>> Result,\n\n")
+	fmt.Fprintf(&buf, format, "ImportPath", "Build", "Test")
+
+	for _, res := range results {
+		fmt.Fprintf(&buf, format, res.pkg.ImportPath, res.build, res.test)
+	}
+
+	return buf.String()
+}
+
+// rollback set the updated packages back to their previous version and
+// re-install them.
+func (env *Env) rollback(updated map[string]string) (err error) {
+	for _, pkg := range env.pkgs {
+		oldVersion, ok := updated[pkg.ImportPath]
+		if !ok {
+			continue
+		}
+
+		env.log.Printf("[ENV] rollback %s >>> %s\n", pkg.ImportPath, oldVersion)
+
+		err = pkg.CheckoutVersion(oldVersion)
+		if err != nil {
+			return fmt.Errorf("rollback: %s: %w", pkg.ImportPath, err)
+		}
+
+		pkg.Version = oldVersion
+		pkg.isTag = IsTagVersion(oldVersion)
+
+		err = pkg.resolveHash()
+		if err != nil {
+			return fmt.Errorf("rollback: %s: %w", pkg.ImportPath, err)
+		}
+
+		_ = pkg.GoInstall(env.path)
+	}
+
+	env.dirty = true
+
+	return nil
+}
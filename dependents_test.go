@@ -0,0 +1,91 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestEnvDependents(t *testing.T) {
+	// The dependencies graph: "lib" is required by "mid" and "app",
+	// and "mid" is required by "app".
+	env := &Env{
+		pkgs: []*Package{{
+			ImportPath: "example.com/app",
+			RemoteURL:  "https://example.com/app",
+			Deps:       []string{"example.com/lib", "example.com/mid"},
+		}, {
+			ImportPath: "example.com/lib",
+			RemoteURL:  "https://example.com/lib",
+			RequiredBy: []string{"example.com/mid", "example.com/app"},
+		}, {
+			ImportPath: "example.com/mid",
+			RemoteURL:  "https://example.com/mid",
+			Deps:       []string{"example.com/lib"},
+			RequiredBy: []string{"example.com/app"},
+		}, {
+			ImportPath: "example.com/other",
+			RemoteURL:  "https://example.com/other",
+		}},
+	}
+
+	cases := []struct {
+		desc    string
+		updated map[string]string
+		exp     []string
+	}{{
+		desc: "With updated package not required",
+		updated: map[string]string{
+			"example.com/app": "v0.1.0",
+		},
+	}, {
+		desc: "With updated package required by others",
+		updated: map[string]string{
+			"example.com/lib": "v0.1.0",
+		},
+		exp: []string{"example.com/mid", "example.com/app"},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		var got []string
+		for _, pkg := range env.dependents(c.updated) {
+			got = append(got, pkg.ImportPath)
+		}
+
+		test.Assert(t, "dependents", c.exp, got)
+	}
+}
+
+func TestEnvFormatDependentResults(t *testing.T) {
+	env := &Env{
+		fmtMaxPath: 15,
+	}
+
+	results := []*dependentResult{{
+		pkg:   &Package{ImportPath: "example.com/mid"},
+		build: resultPass,
+		test:  resultFail,
+	}, {
+		pkg:   &Package{ImportPath: "example.com/app"},
+		build: resultFail,
+		test:  resultSkip,
+	}}
+
+	exp := `
+[ENV] testDependents >>> Result,
+
+ImportPath       Build  Test 
+example.com/mid  pass   fail 
+example.com/app  fail   skip 
+`
+
+	got := env.formatDependentResults(results)
+
+	test.Assert(t, "formatDependentResults", exp, got)
+}
@@ -0,0 +1,182 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"strings"
+
+	libio "github.com/shuLhan/share/lib/io"
+)
+
+// DirtyMode define how to handle package with local changes in their
+// working tree (modified or untracked files, or commits that does not
+// exist on remote), before its checked out to other version.
+type DirtyMode int
+
+// List of modes to handle package with local changes.
+const (
+	// DirtyRefuse skip the package and keep their local changes.
+	// This is the default mode.
+	DirtyRefuse DirtyMode = iota
+
+	// DirtyStash save the modified and untracked files into git stash
+	// and the local commits into new branch, before the package is
+	// checked out.
+	// After the package is checked out, the local commits and files are
+	// restored on top of the new version.
+	// If they can not be restored due to conflict, they are kept in the
+	// branch or stash.
+	DirtyStash
+
+	// DirtyForce discard all local changes.
+	DirtyForce
+)
+
+// dirtyStatus contains the local changes in package working tree that
+// will be lost when package is checked out to other version.
+type dirtyStatus struct {
+	// files is the number of modified or untracked files.
+	files int
+
+	// commits is the number of local commits that does not exist on
+	// remote branches or tags.
+	commits int
+
+	// name is the name of stash message and branch where the local
+	// changes are saved.
+	name string
+}
+
+// isDirty will return true if working tree have local changes.
+func (st *dirtyStatus) isDirty() bool {
+	return st.files > 0 || st.commits > 0
+}
+
+// String return the human readable local changes.
+func (st *dirtyStatus) String() string {
+	var s []string
+
+	if st.files > 0 {
+		s = append(s, fmt.Sprintf("%d modified or untracked files", st.files))
+	}
+	if st.commits > 0 {
+		s = append(s, fmt.Sprintf("%d local commits", st.commits))
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// dirtyStatus return the local changes on package working tree.
+func (pkg *Package) dirtyStatus() (st *dirtyStatus, err error) {
+	st = &dirtyStatus{}
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitDirtyStatus(st)
+	}
+	return st, err
+}
+
+// stash save the local changes on package working tree, so it can be
+// restored by unstash after the package is checked out.
+func (pkg *Package) stash(st *dirtyStatus, op string) (err error) {
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitStash(st, op)
+	}
+	if err == nil {
+		pkg.stashed = st
+	}
+	return err
+}
+
+// unstash restore the local changes that has been saved by stash into
+// package working tree.
+// If the changes can not be restored due to conflict, it will return an
+// error and the changes are kept where they are saved.
+func (pkg *Package) unstash() (err error) {
+	st := pkg.stashed
+	if st == nil {
+		return nil
+	}
+	pkg.stashed = nil
+
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitUnstash(st)
+	}
+	return err
+}
+
+// checkDirty check the package working tree for local changes before its
+// checked out by operation op.
+// It will return true if the package can be checked out; or false if the
+// package have local changes and should be skipped.
+func (env *Env) checkDirty(pkg *Package, op string) (ok bool, err error) {
+	if libio.IsDirEmpty(pkg.FullPath) {
+		return true, nil
+	}
+
+	st, err := pkg.dirtyStatus()
+	if err != nil {
+		return false, err
+	}
+	if !st.isDirty() {
+		return true, nil
+	}
+
+	switch env.DirtyMode {
+	case DirtyForce:
+		env.log.Printf("[ENV] %s %s >>> Discarding %s\n", op, pkg.ImportPath, st)
+		return true, nil
+
+	case DirtyStash:
+		env.log.Printf("[ENV] %s %s >>> Stashing %s\n", op, pkg.ImportPath, st)
+		err = pkg.stash(st, op)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	env.log.Printf("[ENV] %s %s >>> Skipped, working tree has %s\n", op,
+		pkg.ImportPath, st)
+
+	env.pkgsSkipped = append(env.pkgsSkipped,
+		fmt.Sprintf("%s (%s)", pkg.ImportPath, st))
+
+	return false, nil
+}
+
+// restoreDirty restore the local changes of package that has been stashed
+// by checkDirty, after the package is checked out by operation op.
+// If the changes can not be restored, it will print the conflict and where
+// the changes are kept.
+func (env *Env) restoreDirty(pkg *Package, op string) {
+	if pkg.stashed == nil {
+		return
+	}
+
+	env.log.Printf("[ENV] %s %s >>> Restoring %s\n", op, pkg.ImportPath,
+		pkg.stashed)
+
+	err := pkg.unstash()
+	if err != nil {
+		env.log.Warnf("[ENV] %s %s >>> %s\n", op, pkg.ImportPath, err)
+	}
+}
+
+// printSkipped print the packages that has been skipped by operation op
+// due to local changes or unsigned version, and then reset the list.
+func (env *Env) printSkipped(op string) {
+	if len(env.pkgsSkipped) == 0 {
+		return
+	}
+
+	env.log.Printf("\n[ENV] %s >>> The following packages are skipped,\n", op)
+	for _, skipped := range env.pkgsSkipped {
+		env.log.Printf("  * %s\n", skipped)
+	}
+	env.log.Println()
+
+	env.pkgsSkipped = nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func testCreateDirtyRepo(t *testing.T) (pkg *Package) {
+	dir := t.TempDir()
+	remoteDir := filepath.Join(dir, "remote.git")
+	repoDir := filepath.Join(dir, "repo")
+
+	err := os.MkdirAll(remoteDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGit(t, remoteDir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--bare", "--initial-branch="+gitDefBranch)
+	testGit(t, dir, "2018-01-01T00:00:00Z", "clone", "--quiet", remoteDir,
+		repoDir)
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "first")
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "push", "--quiet",
+		"origin", gitDefBranch)
+
+	return &Package{
+		ImportPath: "example.com/repo",
+		FullPath:   repoDir,
+		vcsMode:    VCSModeGit,
+	}
+}
+
+func TestPackageDirtyStatus(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+
+	st, err := pkg.dirtyStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "clean", &dirtyStatus{}, st)
+
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "local")
+	testWriteFiles(t, pkg.FullPath, map[string]string{
+		"untracked.go": "package repo\n",
+	})
+
+	st, err = pkg.dirtyStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "dirty", &dirtyStatus{files: 1, commits: 1}, st)
+	test.Assert(t, "String", "1 modified or untracked files, 1 local commits",
+		st.String())
+
+	err = pkg.stash(st, "Freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The local commits is still on the current branch, but it
+	// now exist on the new branch.
+	st, err = pkg.dirtyStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "after stash", &dirtyStatus{commits: 1}, st)
+}
+
+func TestEnvCheckDirty(t *testing.T) {
+	cases := []struct {
+		desc       string
+		mode       DirtyMode
+		expOK      bool
+		expSkipped int
+	}{{
+		desc:       "With refuse mode",
+		mode:       DirtyRefuse,
+		expSkipped: 1,
+	}, {
+		desc:  "With force mode",
+		mode:  DirtyForce,
+		expOK: true,
+	}, {
+		desc:  "With stash mode",
+		mode:  DirtyStash,
+		expOK: true,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		pkg := testCreateDirtyRepo(t)
+		testWriteFiles(t, pkg.FullPath, map[string]string{
+			"untracked.go": "package repo\n",
+		})
+
+		env := &Env{
+			DirtyMode: c.mode,
+		}
+
+		ok, err := env.checkDirty(pkg, "Test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, "ok", c.expOK, ok)
+		test.Assert(t, "pkgsSkipped", c.expSkipped, len(env.pkgsSkipped))
+	}
+}
+
+// testTagRepo tag the package repository as version "v1.0.0".
+// If withUpdate is true, new version "v1.1.0" is pushed to remote but
+// not checked out.
+func testTagRepo(t *testing.T, pkg *Package, importPath string, withUpdate bool) {
+	testGit(t, pkg.FullPath, "2018-01-01T00:00:00Z", "tag", "v1.0.0")
+
+	pkg.ImportPath = importPath
+	pkg.RemoteName = gitDefRemoteName
+	pkg.Version = "v1.0.0"
+	pkg.isTag = true
+
+	if !withUpdate {
+		return
+	}
+
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "commit",
+		"--quiet", "--allow-empty", "-m", "second")
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "tag", "v1.1.0")
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "push", "--quiet",
+		"--tags", "origin", gitDefBranch)
+	testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "reset",
+		"--quiet", "--hard", "v1.0.0")
+}
+
+func TestEnvSyncAllSkipDirtyWithoutUpdate(t *testing.T) {
+	var (
+		out     bytes.Buffer
+		updated = testCreateDirtyRepo(t)
+		dirty   = testCreateDirtyRepo(t)
+	)
+
+	testTagRepo(t, updated, "example.com/updated", true)
+	testTagRepo(t, dirty, "example.com/dirty", false)
+
+	testWriteFiles(t, dirty.FullPath, map[string]string{
+		"untracked.go": "package repo\n",
+	})
+
+	env := &Env{
+		pkgs:      []*Package{updated, dirty},
+		log:       NewLogger(&out, &out, LogNormal),
+		NoConfirm: true,
+	}
+	for _, pkg := range env.pkgs {
+		env.setPackageEnv(pkg)
+	}
+
+	_ = env.SyncAll()
+
+	test.Assert(t, "updated version", "v1.1.0", updated.Version)
+	test.Assert(t, "dirty version", "v1.0.0", dirty.Version)
+	test.Assert(t, "dirty is not skipped", false,
+		strings.Contains(out.String(), "Skipped"))
+
+	_, err := os.Stat(filepath.Join(dirty.FullPath, "untracked.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSetGitIdentity set the git author and committer, so the commits can
+// be created by beku, for example when restoring the local commits.
+func testSetGitIdentity(t *testing.T) {
+	for _, name := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(name+"_NAME", "beku")
+		t.Setenv(name+"_EMAIL", "beku@localhost")
+	}
+}
+
+func TestPackageUnstash(t *testing.T) {
+	testSetGitIdentity(t)
+
+	cases := []struct {
+		desc     string
+		local    map[string]string
+		expErr   string
+		expFiles []string
+		expStash int
+	}{{
+		desc: "Without conflict",
+		local: map[string]string{
+			"untracked.go": "package repo\n",
+		},
+		expFiles: []string{"committed.go", "untracked.go", "update.go"},
+	}, {
+		desc: "With conflict on files",
+		local: map[string]string{
+			"update.go": "package local\n",
+		},
+		expErr:   "kept in stash",
+		expFiles: []string{"committed.go", "update.go"},
+		expStash: 1,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		pkg := testCreateDirtyRepo(t)
+		testTagRepo(t, pkg, "example.com/repo", false)
+
+		// Push new version that add file "update.go".
+		testWriteFiles(t, pkg.FullPath, map[string]string{
+			"update.go": "package repo\n",
+		})
+		testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "add", ".")
+		testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "commit",
+			"--quiet", "-m", "update")
+		testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "tag", "v1.1.0")
+		testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "push",
+			"--quiet", "--tags", "origin", gitDefBranch)
+		testGit(t, pkg.FullPath, "2018-01-02T00:00:00Z", "reset",
+			"--quiet", "--hard", "v1.0.0")
+
+		// Create local commit and local files.
+		testWriteFiles(t, pkg.FullPath, map[string]string{
+			"committed.go": "package repo\n",
+		})
+		testGit(t, pkg.FullPath, "2018-01-03T00:00:00Z", "add", ".")
+		testGit(t, pkg.FullPath, "2018-01-03T00:00:00Z", "commit",
+			"--quiet", "-m", "local")
+		testWriteFiles(t, pkg.FullPath, c.local)
+
+		st, err := pkg.dirtyStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = pkg.stash(st, "Test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = pkg.CheckoutVersion("v1.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = pkg.unstash()
+		if len(c.expErr) > 0 {
+			test.Assert(t, "error contains "+c.expErr, true,
+				err != nil && strings.Contains(err.Error(), c.expErr))
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range c.expFiles {
+			_, err = os.Stat(filepath.Join(pkg.FullPath, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		parent, err := pkg.gitOutput("rev-parse", "HEAD^")
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, err := pkg.gitOutput("rev-parse", "v1.1.0^{commit}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, "local commit on top of new version", version,
+			parent)
+
+		branches, err := pkg.gitOutput("branch", "--list", "beku/*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, "stash branch is removed", "", branches)
+
+		stashes, err := pkg.gitOutput("stash", "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotStash int
+		if len(stashes) > 0 {
+			gotStash = len(strings.Split(stashes, "\n"))
+		}
+		test.Assert(t, "number of stash", c.expStash, gotStash)
+	}
+}
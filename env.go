@@ -8,14 +8,16 @@ package beku
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"go/build"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/shuLhan/share/lib/debug"
 	"github.com/shuLhan/share/lib/ini"
 	libio "github.com/shuLhan/share/lib/io"
 )
@@ -26,6 +28,9 @@ import (
 type Env struct {
 	path   string
 	prefix string // Equal to GOPATH.
+	goroot string
+	goos   string
+	goarch string
 
 	dirBin       string
 	dirGoRootSrc string
@@ -35,13 +40,68 @@ type Env struct {
 	dbDefFile string
 	dbFile    string
 
+	// MirrorDir define the directory of bare repositories.
+	// If its set, all packages will be cloned and fetched from this
+	// directory instead of from network.
+	MirrorDir string
+
+	// FromMirror if its true, new package will be cloned from
+	// MirrorDir but fetched from their remote URL.
+	FromMirror bool
+
+	// ChangelogFile define the file where the summary of commits of all
+	// packages that will be updated by SyncAll is written, before
+	// asking for confirmation.
+	ChangelogFile string
+
+	// CheckAPI if its true, SyncAll compare the exported API of each
+	// package on current and new version before asking for
+	// confirmation, and mark the update that remove or change exported
+	// identifier as breaking.
+	CheckAPI bool
+
+	// DirtyMode define how to handle package that have local changes
+	// before its checked out to other version.
+	DirtyMode DirtyMode
+
+	// TestDependents if its true, all packages that depends on the
+	// updated packages will be build and tested after update.
+	TestDependents bool
+
+	// Prompter define the interface to ask user for confirmation.
+	// If its nil, the question is answered from standard input.
+	Prompter Prompter
+
+	// Observer receive the lifecycle events of packages and database.
+	// If its nil, no events are emitted.
+	Observer Observer
+
+	// KeepGoing if its true, Freeze and SyncMany continue with the next
+	// package when one of package failed, and the package that failed to
+	// be installed by "go install" is reported as failed on SyncMany and
+	// SyncAll.
+	// SyncAll always continue with the next package, since the packages
+	// are checked out only after all of them has been fetched.
+	KeepGoing bool
+
 	pkgs        []*Package
 	pkgsExclude []string
 	pkgsMissing []string
 	pkgsStd     []string
 	pkgsUnused  []*Package
+	pkgsSkipped []string
+
+	urlRewrites urlRewrites
+	compareURLs compareURLTemplates
+	signature   *signaturePolicy
 
-	db *ini.Ini
+	licenseAllow []string
+	hooks        []*hook
+
+	db     *ini.Ini
+	vanity *vanityCache
+	log    *Logger
+	ctx    context.Context
 
 	countNew    int
 	countUpdate int
@@ -53,37 +113,77 @@ type Env struct {
 }
 
 // NewEnvironment will gather all information in user system.
-func NewEnvironment(noDeps bool) (env *Env, err error) {
-	if len(build.Default.GOROOT) == 0 {
-		return nil, ErrGOROOT
-	}
-
+// By default, the environment use the GOPATH, GOROOT, GOOS, GOARCH, and PATH
+// from user system; each of them can be changed using options opts.
+func NewEnvironment(opts ...Option) (env *Env, err error) {
 	env = &Env{
 		path:   os.Getenv(envPATH),
 		prefix: build.Default.GOPATH,
+		goroot: build.Default.GOROOT,
+		goos:   build.Default.GOOS,
+		goarch: build.Default.GOARCH,
 
-		dirBin:       filepath.Join(build.Default.GOPATH, dirBin),
-		dirGoRootSrc: filepath.Join(build.Default.GOROOT, dirSrc),
-		dirPkg:       filepath.Join(build.Default.GOPATH, dirPkg, build.Default.GOOS+"_"+build.Default.GOARCH),
-		dirSrc:       filepath.Join(build.Default.GOPATH, dirSrc),
-
-		dbDefFile: filepath.Join(build.Default.GOPATH, dirDB, DefDBName),
+		log: NewLogger(nil, nil, defLogLevel()),
+	}
 
-		noDeps: noDeps,
+	for _, opt := range opts {
+		opt(env)
 	}
 
+	if len(env.goroot) == 0 {
+		return nil, ErrGOROOT
+	}
 	if len(env.path) == 0 {
 		env.path = defPATH
 	}
 
+	env.dirBin = filepath.Join(env.prefix, dirBin)
+	env.dirGoRootSrc = filepath.Join(env.goroot, dirSrc)
+	env.dirPkg = filepath.Join(env.prefix, dirPkg, env.goos+"_"+env.goarch)
+	env.dirSrc = filepath.Join(env.prefix, dirSrc)
+
+	if len(env.dbDefFile) == 0 {
+		env.dbDefFile = filepath.Join(env.prefix, dirDB, DefDBName)
+	}
+
+	env.vanity = newVanityCache(filepath.Join(env.prefix, dirDB,
+		DefVanityCacheName), DefVanityTTL)
+	env.vanity.log = env.log
+
 	err = env.scanStdPackages(env.dirGoRootSrc)
 	if err != nil {
 		return nil, err
 	}
 
+	env.hooks, err = loadHooks(filepath.Join(env.prefix, dirDB, dirHooks))
+	if err != nil {
+		return nil, err
+	}
+
 	return env, nil
 }
 
+// SetOutput set the writer for messages and errors of environment and all
+// of its packages.
+// If out or errOut is nil, it will use the standard output or error.
+func (env *Env) SetOutput(out, errOut io.Writer) {
+	if env.log == nil {
+		env.log = NewLogger(out, errOut, defLogLevel())
+		return
+	}
+	env.log.SetOutput(out, errOut)
+}
+
+// SetLogLevel set the level of messages that is printed by environment and
+// all of its packages.
+func (env *Env) SetLogLevel(level LogLevel) {
+	if env.log == nil {
+		env.log = NewLogger(nil, nil, level)
+		return
+	}
+	env.log.SetLevel(level)
+}
+
 func (env *Env) initGopath() {
 }
 
@@ -128,51 +228,144 @@ func (env *Env) Exclude(importPaths []string) {
 
 // Freeze all packages in database. Install all registered packages in
 // database and remove non-registered from "src" and "pkg" directories.
+//
+// If one of package failed, it will stop and return the *PackageError,
+// unless KeepGoing is true; in that case all packages are frozen and the
+// errors are returned as PackageErrors.
 func (env *Env) Freeze() (err error) {
-	var localPkg *Package
+	return env.FreezeContext(context.Background())
+}
 
-	for _, pkg := range env.pkgs {
-		fmt.Printf("\n[ENV] Freeze >>> %s@%s\n", pkg.ImportPath, pkg.Version)
+// FreezeContext is like Freeze but it stop when the context ctx is done.
+// The package that is being frozen when the context is done may be left
+// partially checked out, and will be frozen again on the next Freeze.
+func (env *Env) FreezeContext(ctx context.Context) (err error) {
+	var errs PackageErrors
 
-		localPkg, err = env.GetLocalPackage(pkg.ImportPath)
+	defer env.withContext(ctx)()
+
+	for _, pkg := range env.pkgs {
+		err = env.canceled("Freeze")
 		if err != nil {
-			return
+			return err
 		}
-		if localPkg == nil {
-			err = pkg.Install()
-			if err != nil {
-				return
-			}
+
+		env.log.Printf("\n[ENV] Freeze >>> %s@%s\n", pkg.ImportPath, pkg.Version)
+
+		err = env.freeze(pkg)
+		if err == nil {
 			continue
 		}
+		if !env.KeepGoing {
+			return env.contextErr("Freeze", &PackageError{
+				ImportPath: pkg.ImportPath,
+				Op:         "Freeze",
+				Err:        err,
+			})
+		}
 
-		err = pkg.Freeze()
+		err = env.collectError(&errs, "Freeze", pkg.ImportPath, err)
 		if err != nil {
-			return
+			return err
 		}
 	}
 
+	env.printSkipped("Freeze")
+	env.printFailed("Freeze", errs)
+
+	err = env.canceled("Freeze")
+	if err != nil {
+		return err
+	}
+
 	env.pkgsUnused = nil
 
 	err = env.GetUnused(env.dirSrc)
 	if err != nil {
-		err = fmt.Errorf("Freeze: %s", err.Error())
+		err = fmt.Errorf("Freeze: %w", err)
 		return
 	}
 
 	if len(env.pkgsUnused) == 0 {
-		fmt.Println("\n[ENV] Freeze >>> No unused packages found.")
+		env.log.Println("\n[ENV] Freeze >>> No unused packages found.")
 		goto out
 	}
 
-	fmt.Println("[ENV] Freeze >>> The following packages is unused,")
+	env.log.Println("[ENV] Freeze >>> The following packages is unused,")
 	for _, pkg := range env.pkgsUnused {
-		fmt.Printf("  * %s\n", pkg.ImportPath)
+		env.log.Printf("  * %s\n", pkg.ImportPath)
 	}
 
-	fmt.Println()
+	env.log.Println()
 out:
-	fmt.Println("[ENV] Freeze >>> finished")
+	env.log.Println("[ENV] Freeze >>> finished")
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// freeze install the package if its not exist, or set the package
+// repository to the package remote and version in database.
+func (env *Env) freeze(pkg *Package) (err error) {
+	err = env.useMirror(pkg)
+	if err != nil {
+		return err
+	}
+
+	localPkg, err := env.GetLocalPackage(pkg.ImportPath)
+	if err != nil {
+		return err
+	}
+
+	var oldVersion string
+	if localPkg != nil {
+		oldVersion = localPkg.Version
+	}
+
+	if localPkg != nil {
+		// Verify the version before running the hooks or stashing
+		// the local changes, so nothing is changed if its not signed.
+		err = pkg.verifyRemote(pkg.vcsRemoteURL(), pkg.Version)
+		if errors.Is(err, ErrUnsigned) {
+			env.skipUnsigned(pkg, "Freeze", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = env.runHooks(hookPre, hookOpFreeze, pkg, oldVersion, pkg.Version)
+	if err != nil {
+		env.skipHook(pkg, "Freeze", err)
+		return nil
+	}
+
+	if localPkg == nil {
+		err = env.installPackage(pkg)
+		if err != nil {
+			return err
+		}
+	} else {
+		ok, err := env.checkDirty(pkg, "Freeze")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		err = pkg.Freeze()
+		env.restoreDirty(pkg, "Freeze")
+		if err != nil {
+			return err
+		}
+	}
+
+	_ = env.runHooks(hookPost, hookOpFreeze, pkg, oldVersion, pkg.Version)
 
 	return nil
 }
@@ -193,12 +386,12 @@ func (env *Env) GetLocalPackage(importPath string) (pkg *Package, err error) {
 		if libio.IsDirEmpty(fullPath) {
 			err = nil
 		} else {
-			err = fmt.Errorf(errDirNotEmpty, fullPath)
+			err = fmt.Errorf("%w: %s", ErrDirNotEmpty, fullPath)
 		}
 		return
 	}
 
-	pkg, err = NewPackage(env.dirSrc, importPath, importPath)
+	pkg, err = env.resolveLocalPackage(importPath)
 	if err != nil {
 		return
 	}
@@ -232,7 +425,7 @@ func (env *Env) GetPackageFromDB(importPath, remoteURL string) (int, *Package) {
 func (env *Env) GetUnused(srcPath string) (err error) {
 	fis, err := ioutil.ReadDir(srcPath)
 	if err != nil {
-		err = fmt.Errorf("CleanPackages: %s", err)
+		err = fmt.Errorf("CleanPackages: %w", err)
 		return
 	}
 
@@ -270,7 +463,7 @@ func (env *Env) GetUnused(srcPath string) (err error) {
 			continue
 		}
 
-		pkg, err = NewPackage(env.dirSrc, importPath, importPath)
+		pkg, err = env.resolveLocalPackage(importPath)
 		if err != nil {
 			return
 		}
@@ -302,17 +495,66 @@ func (env *Env) IsExcluded(importPath string) bool {
 	return false
 }
 
+// MirrorUpdate create or update the bare mirror repository of each package
+// in database into directory "dir".
+// The mirror of each package is stored in "{dir}/{import-path}.git" and can
+// be used later as MirrorDir.
+func (env *Env) MirrorUpdate(dir string) (err error) {
+	return env.MirrorUpdateContext(context.Background(), dir)
+}
+
+// MirrorUpdateContext is like MirrorUpdate but it stop when the context ctx
+// is done.
+func (env *Env) MirrorUpdateContext(ctx context.Context, dir string) (err error) {
+	defer env.withContext(ctx)()
+
+	for _, pkg := range env.pkgs {
+		err = env.canceled("MirrorUpdate")
+		if err != nil {
+			return err
+		}
+
+		repoDir := mirrorPath(dir, pkg.ImportPath)
+
+		remoteURL := env.urlRewrites.rewrite(pkg.RemoteURL)
+
+		env.log.Printf("[ENV] MirrorUpdate >>> %s %s\n", remoteURL, repoDir)
+
+		err = mirrorUpdate(env.context(), env.log, remoteURL, repoDir)
+		if err != nil {
+			return env.contextErr("MirrorUpdate",
+				fmt.Errorf("MirrorUpdate: %s: %w", pkg.ImportPath, err))
+		}
+	}
+
+	env.log.Println("[ENV] MirrorUpdate >>> finished")
+
+	return nil
+}
+
 // Scan will gather all package information in user system to start `beku`-ing.
 func (env *Env) Scan() (err error) {
+	return env.ScanContext(context.Background())
+}
+
+// ScanContext is like Scan but it stop when the context ctx is done.
+func (env *Env) ScanContext(ctx context.Context) (err error) {
+	defer env.withContext(ctx)()
+
 	err = env.scanPackages(env.dirSrc)
 	if err != nil {
-		return
+		return env.contextErr("Scan", err)
 	}
 
 	for x := 0; x < len(env.pkgs); x++ {
+		err = env.canceled("Scan")
+		if err != nil {
+			return err
+		}
+
 		err = env.pkgs[x].ScanDeps(env)
 		if err != nil {
-			return
+			return env.contextErr("Scan", err)
 		}
 	}
 
@@ -325,7 +567,7 @@ func (env *Env) Scan() (err error) {
 func (env *Env) scanStdPackages(srcPath string) error {
 	fis, err := ioutil.ReadDir(srcPath)
 	if err != nil {
-		err = fmt.Errorf("scanStdPackages: %s", err)
+		err = fmt.Errorf("scanStdPackages: %w", err)
 		return err
 	}
 
@@ -352,8 +594,8 @@ func (env *Env) scanStdPackages(srcPath string) error {
 // scanPackages will traverse each directory in `src` recursively until
 // it's found VCS metadata, e.g. `.git` directory.
 func (env *Env) scanPackages(srcPath string) (err error) {
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] scanPackages >>>", srcPath)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] scanPackages >>>", srcPath)
 	}
 
 	fis, err := ioutil.ReadDir(srcPath)
@@ -362,7 +604,7 @@ func (env *Env) scanPackages(srcPath string) (err error) {
 			err = nil
 			return
 		}
-		err = fmt.Errorf("scanPackages: %s", err)
+		err = fmt.Errorf("scanPackages: %w", err)
 		return
 	}
 
@@ -390,6 +632,11 @@ func (env *Env) scanPackages(srcPath string) (err error) {
 			continue
 		}
 
+		err = env.canceled("scanPackages")
+		if err != nil {
+			return err
+		}
+
 		err = env.newPackage(fullPath)
 		if err != nil {
 			return
@@ -415,13 +662,13 @@ func (env *Env) newPackage(fullPath string) (err error) {
 		return
 	}
 
-	pkg, err := NewPackage(env.dirSrc, pkgName, pkgName)
+	pkg, err := env.resolveLocalPackage(pkgName)
 	if err != nil {
 		return
 	}
 
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] newPackage >>>", pkg.ImportPath)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] newPackage >>>", pkg.ImportPath)
 	}
 
 	err = pkg.Scan()
@@ -430,7 +677,7 @@ func (env *Env) newPackage(fullPath string) (err error) {
 			err = nil
 			return
 		}
-		return fmt.Errorf("%s: %s", pkgName, err)
+		return fmt.Errorf("%s: %w", pkgName, err)
 	}
 
 	_, curPkg := env.GetPackageFromDB(pkg.ImportPath, pkg.RemoteURL)
@@ -442,6 +689,12 @@ func (env *Env) newPackage(fullPath string) (err error) {
 			env.fmtMaxPath = len(pkg.ImportPath)
 		}
 
+		env.emit(Event{
+			Kind:       EventPackageDiscovered,
+			ImportPath: pkg.ImportPath,
+			NewVersion: pkg.Version,
+		})
+
 		return nil
 	}
 
@@ -450,12 +703,157 @@ func (env *Env) newPackage(fullPath string) (err error) {
 			curPkg.VersionNext = pkg.Version
 			curPkg.state = packageStateChange
 			env.countUpdate++
+
+			env.emit(Event{
+				Kind:       EventVersionPlanned,
+				ImportPath: curPkg.ImportPath,
+				OldVersion: curPkg.Version,
+				NewVersion: curPkg.VersionNext,
+			})
 		}
 	}
 
 	return nil
 }
 
+// resolvePackage create new package from package name.
+// If mirror directory is set, the package is resolved using the mirror
+// without any network access.
+// Otherwise, the vanity import path is resolved using the cache before
+// using network.
+func (env *Env) resolvePackage(name, importPath string) (pkg *Package, err error) {
+	if env.isOffline() {
+		pkg, err = NewPackageMirror(env.log, env.dirSrc, env.MirrorDir,
+			name, importPath)
+		if err != nil {
+			return nil, err
+		}
+		env.setURLRewrites(pkg)
+		env.setPackageEnv(pkg)
+		return pkg, nil
+	}
+
+	repoRoot, err := env.vanity.repoRootForImportPath(name)
+	if err != nil {
+		env.log.Warnf("[ENV] resolvePackage >>> error: %s\n", err)
+		return nil, err
+	}
+
+	pkg, err = newPackageFromRepoRoot(env.log, env.dirSrc, importPath,
+		repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	env.setURLRewrites(pkg)
+	env.setPackageEnv(pkg)
+
+	return pkg, nil
+}
+
+// resolveUpstream set the package upstream URL, by resolving the package
+// import path, if the package is downloaded from fork.
+// Failing to resolve the upstream is not an error, since the import path may
+// not be exist as remote repository.
+func (env *Env) resolveUpstream(pkg *Package) {
+	if env.isOffline() {
+		return
+	}
+
+	repoRoot, err := env.vanity.repoRootForImportPath(pkg.ImportPath)
+	if err != nil {
+		env.log.Warnf("[ENV] resolveUpstream >>> %s\n", err)
+		return
+	}
+
+	upstreamURL := env.urlRewrites.canonical(repoRoot.Repo)
+	if upstreamURL != pkg.RemoteURL {
+		pkg.UpstreamURL = upstreamURL
+	}
+}
+
+// resolveLocalPackage create new package from repository that already
+// exist in "src" directory.
+// The package is created from local repository metadata, so scanning
+// packages does not require network.
+// If the local repository does not have remote, it will fallback to
+// resolvePackage.
+func (env *Env) resolveLocalPackage(importPath string) (pkg *Package, err error) {
+	pkg, err = NewPackageLocal(env.log, env.dirSrc, importPath)
+	if err == nil {
+		env.setURLRewrites(pkg)
+		env.setPackageEnv(pkg)
+		return pkg, nil
+	}
+
+	env.log.Verbosef("[ENV] resolveLocalPackage >>> %s\n", err)
+
+	return env.resolvePackage(importPath, importPath)
+}
+
+// setURLRewrites set the package URL rewrite rules and revert the package
+// remote URL to its canonical form, in case its read from repository that
+// use rewritten URL.
+func (env *Env) setURLRewrites(pkg *Package) {
+	pkg.urlRewrites = env.urlRewrites
+	pkg.RemoteURL = env.urlRewrites.canonical(pkg.RemoteURL)
+}
+
+// setPackageEnv set the package signature policy, logger, context, and Go
+// environment variables from environment.
+func (env *Env) setPackageEnv(pkg *Package) {
+	pkg.signature = env.signature
+	pkg.log = env.log
+	pkg.ctx = env.ctx
+	pkg.goEnv = env.goEnviron()
+}
+
+// goEnviron return the environment variables of Go that is used when
+// running "go" commands on package.
+func (env *Env) goEnviron() (environ []string) {
+	if len(env.prefix) == 0 {
+		return nil
+	}
+	environ = append(environ, "GOPATH="+env.prefix)
+	if len(env.goroot) > 0 {
+		environ = append(environ, "GOROOT="+env.goroot)
+	}
+	if len(env.goos) > 0 {
+		environ = append(environ, "GOOS="+env.goos)
+	}
+	if len(env.goarch) > 0 {
+		environ = append(environ, "GOARCH="+env.goarch)
+	}
+	return environ
+}
+
+// useMirror set the package to be cloned and fetched from mirror directory,
+// only if the mirror directory is set.
+func (env *Env) useMirror(pkg *Package) (err error) {
+	if !env.isOffline() {
+		return nil
+	}
+
+	pkg.mirrorURL, err = mirrorLookup(env.MirrorDir, pkg.ImportPath)
+
+	return err
+}
+
+// isOffline will return true if all packages should be cloned and fetched
+// from mirror directory.
+func (env *Env) isOffline() bool {
+	return len(env.MirrorDir) > 0 && !env.FromMirror
+}
+
+// installPackage install the package using mirror directory, if FromMirror
+// is true; otherwise install the package from their remote URL.
+func (env *Env) installPackage(pkg *Package) error {
+	if env.FromMirror {
+		return pkg.InstallFromMirror(env.MirrorDir)
+	}
+	return pkg.Install()
+}
+
 func (env *Env) addPackage(pkg *Package) {
 	for x := 0; x < len(pkg.DepsMissing); x++ {
 		env.addPackageMissing(pkg.DepsMissing[x])
@@ -488,8 +886,8 @@ func (env *Env) Load(file string) (err error) {
 		env.dbFile = file
 	}
 
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] Load >>>", env.dbFile)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] Load >>>", env.dbFile)
 	}
 
 	env.db, err = ini.Open(env.dbFile)
@@ -507,6 +905,40 @@ func (env *Env) loadBeku() {
 	for _, v := range env.db.Gets(sectionBeku, "", keyExclude) {
 		env.addExclude(v)
 	}
+
+	env.urlRewrites = nil
+	for _, v := range env.db.Gets(sectionBeku, "", keyRewrite) {
+		rule, err := parseURLRewrite(v)
+		if err != nil {
+			env.log.Warnf("[ENV] loadBeku >>> %s\n", err)
+			continue
+		}
+		env.urlRewrites = append(env.urlRewrites, rule)
+	}
+
+	env.compareURLs = nil
+	for _, v := range env.db.Gets(sectionBeku, "", keyCompareURL) {
+		tmpl, err := parseCompareURLTemplate(v)
+		if err != nil {
+			env.log.Warnf("[ENV] loadBeku >>> %s\n", err)
+			continue
+		}
+		env.compareURLs = append(env.compareURLs, tmpl)
+	}
+
+	env.licenseAllow = env.db.Gets(sectionBeku, "", keyLicAllow)
+
+	env.signature = nil
+	policy, _ := env.db.Get(sectionBeku, "", keySignature, "")
+	keyring, _ := env.db.Get(sectionBeku, "", keyKeyring, "")
+	if len(policy) > 0 || len(keyring) > 0 {
+		sp, err := newSignaturePolicy(policy, keyring)
+		if err != nil {
+			env.log.Warnf("[ENV] loadBeku >>> %s\n", err)
+		} else {
+			env.signature = sp
+		}
+	}
 }
 
 func (env *Env) loadPackages() {
@@ -524,6 +956,8 @@ func (env *Env) loadPackages() {
 		}
 
 		pkg.load(sec)
+		env.setURLRewrites(pkg)
+		env.setPackageEnv(pkg)
 
 		env.addPackage(pkg)
 	}
@@ -536,7 +970,7 @@ func (env *Env) Query(pkgs []string) {
 
 	for x := 0; x < len(env.pkgs); x++ {
 		if len(pkgs) == 0 {
-			fmt.Fprintf(defStdout, format, env.pkgs[x].ImportPath,
+			fmt.Fprintf(env.log.Out(), format, env.pkgs[x].ImportPath,
 				env.pkgs[x].Version)
 			continue
 		}
@@ -546,7 +980,7 @@ func (env *Env) Query(pkgs []string) {
 			}
 
 			if env.pkgs[x].ImportPath == pkgs[y] {
-				fmt.Fprintf(defStdout, format,
+				fmt.Fprintf(env.log.Out(), format,
 					env.pkgs[x].ImportPath,
 					env.pkgs[x].Version)
 			}
@@ -554,9 +988,64 @@ func (env *Env) Query(pkgs []string) {
 	}
 }
 
+// QueryUpdate fetch the latest version of packages in database and print
+// the packages that have newer version.
+// For package that is a fork, it will also print the tags in upstream
+// repository that has not been merged into the fork.
+// If pkgs is empty, all packages in database will be queried.
+func (env *Env) QueryUpdate(pkgs []string) (err error) {
+	format := fmt.Sprintf("%%-%ds  %%-12s  %%-12s\n", env.fmtMaxPath)
+	formatUpstream := fmt.Sprintf("%%-%ds  %%s has %%d new tag(s): %%s\n",
+		env.fmtMaxPath)
+
+	for _, pkg := range env.pkgs {
+		if env.IsExcluded(pkg.ImportPath) {
+			continue
+		}
+
+		found := len(pkgs) == 0
+		for x := 0; x < len(pkgs) && !found; x++ {
+			found = pkgs[x] == pkg.ImportPath
+		}
+		if !found {
+			continue
+		}
+
+		err = env.useMirror(pkg)
+		if err != nil {
+			return fmt.Errorf("QueryUpdate: %w", err)
+		}
+
+		err = pkg.FetchLatestVersion()
+		if err != nil {
+			env.log.Warnf("[ENV] QueryUpdate %s >>> %s\n",
+				pkg.ImportPath, err)
+			continue
+		}
+
+		if pkg.Version < pkg.VersionNext {
+			fmt.Fprintf(env.log.Out(), format, pkg.ImportPath,
+				pkg.Version, pkg.VersionNext)
+		}
+		if len(pkg.UpstreamTags) > 0 {
+			fmt.Fprintf(env.log.Out(), formatUpstream, pkg.ImportPath,
+				pkg.UpstreamURL, len(pkg.UpstreamTags),
+				strings.Join(pkg.UpstreamTags, " "))
+		}
+	}
+
+	return nil
+}
+
 // Rescan for new packages.
 func (env *Env) Rescan(firstTime bool) (ok bool, err error) {
-	err = env.Scan()
+	return env.RescanContext(context.Background(), firstTime)
+}
+
+// RescanContext is like Rescan but the scan stop when the context ctx is
+// done.
+func (env *Env) RescanContext(ctx context.Context, firstTime bool) (ok bool, err error) {
+	err = env.ScanContext(ctx)
 	if err != nil {
 		return
 	}
@@ -564,27 +1053,27 @@ func (env *Env) Rescan(firstTime bool) (ok bool, err error) {
 	format := fmt.Sprintf("%%-%ds  %%-12s  %%-12s\n", env.fmtMaxPath)
 
 	if env.countUpdate > 0 {
-		fmt.Println("[ENV] Rescan >>> New updates,")
-		fmt.Printf(format+"\n", "ImportPath", "Old Version", "New Version")
+		env.log.Println("[ENV] Rescan >>> New updates,")
+		env.log.Printf(format+"\n", "ImportPath", "Old Version", "New Version")
 
 		for _, pkg := range env.pkgs {
 			if pkg.state&packageStateChange == 0 {
 				continue
 			}
 
-			fmt.Printf(format, pkg.ImportPath, pkg.Version, pkg.VersionNext)
+			env.log.Printf(format, pkg.ImportPath, pkg.Version, pkg.VersionNext)
 		}
 	}
 	if env.countNew > 0 {
-		fmt.Println("[ENV] Rescan >>> New packages,")
-		fmt.Printf(format+"\n", "ImportPath", "Old Version", "New Version")
+		env.log.Println("[ENV] Rescan >>> New packages,")
+		env.log.Printf(format+"\n", "ImportPath", "Old Version", "New Version")
 
 		for _, pkg := range env.pkgs {
 			if pkg.state&packageStateNew == 0 {
 				continue
 			}
 
-			fmt.Printf(format, pkg.ImportPath, "-", pkg.Version)
+			env.log.Printf(format, pkg.ImportPath, "-", pkg.Version)
 		}
 	}
 
@@ -592,15 +1081,15 @@ func (env *Env) Rescan(firstTime bool) (ok bool, err error) {
 		if firstTime {
 			env.dirty = true
 		} else {
-			fmt.Println("[ENV] Rescan >>> Database is in sync.")
+			env.log.Println("[ENV] Rescan >>> Database is in sync.")
 		}
 		return true, nil
 	}
 
-	fmt.Println()
+	env.log.Println()
 
 	if !env.NoConfirm {
-		ok = libio.ConfirmYesNo(os.Stdin, msgContinue, false)
+		ok = env.confirm(msgContinue, false)
 		if !ok {
 			return
 		}
@@ -615,6 +1104,13 @@ func (env *Env) Rescan(firstTime bool) (ok bool, err error) {
 			env.pkgs[x].Version = env.pkgs[x].VersionNext
 			env.pkgs[x].VersionNext = ""
 			env.pkgs[x].state = packageStateDirty
+
+			// The hashes in database belong to the old version,
+			// replace them with the hashes of scanned version.
+			err = env.pkgs[x].resolveHash()
+			if err != nil {
+				return false, fmt.Errorf("Rescan: %w", err)
+			}
 		}
 	}
 	if env.countNew > 0 {
@@ -635,20 +1131,21 @@ func (env *Env) Rescan(firstTime bool) (ok bool, err error) {
 // their dependencies, as long as they are not required by other package.
 func (env *Env) Remove(rmPkg string, recursive bool) (err error) {
 	if env.IsExcluded(rmPkg) {
-		fmt.Printf(errExcluded, rmPkg)
-		return
+		return &PackageError{
+			ImportPath: rmPkg,
+			Op:         "Remove",
+			Err:        ErrExcluded,
+		}
 	}
 
 	_, pkg := env.GetPackageFromDB(rmPkg, "")
 	if pkg == nil {
-		fmt.Println("Package", rmPkg, "not installed")
+		env.log.Println("Package", rmPkg, "not installed")
 		return
 	}
 
 	if len(pkg.RequiredBy) > 0 {
-		fmt.Fprintln(os.Stderr, `Can't remove package.
-This package is required by,
-`,
+		env.log.Warnf("Can't remove package.\nThis package is required by,\n %v\n",
 			pkg.RequiredBy)
 		return
 	}
@@ -670,40 +1167,57 @@ This package is required by,
 	}
 	listRemoved = append(listRemoved, pkg.ImportPath)
 
-	fmt.Println("[ENV] Remove >>> The following package will be removed,")
+	env.log.Println("[ENV] Remove >>> The following package will be removed,")
 	for _, importPath := range listRemoved {
-		fmt.Println(" *", importPath)
+		env.log.Println(" *", importPath)
 	}
 
 	if !env.NoConfirm {
-		ok := libio.ConfirmYesNo(os.Stdin, msgContinue, false)
+		ok := env.confirm(msgContinue, false)
 		if !ok {
 			return
 		}
 	}
 
 	for _, importPath := range listRemoved {
+		_, rmp := env.GetPackageFromDB(importPath, "")
+		if rmp != nil {
+			err = env.runHooks(hookPre, hookOpRemove, rmp, rmp.Version,
+				rmp.Version)
+			if err != nil {
+				env.skipHook(rmp, "Remove", err)
+				continue
+			}
+		}
+
 		err = env.removePackage(importPath)
 		if err != nil {
-			err = fmt.Errorf("Remove: %s", err)
+			err = fmt.Errorf("Remove: %w", err)
 			return
 		}
 
 		pkgImportPath := filepath.Join(env.dirPkg, importPath)
 
-		if debug.Value >= 1 {
-			fmt.Println("[ENV] Remove >>> Removing", pkgImportPath)
+		if env.log.IsVerbose() {
+			env.log.Println("[ENV] Remove >>> Removing", pkgImportPath)
 		}
 
 		err = os.RemoveAll(pkgImportPath)
 		if err != nil {
-			err = fmt.Errorf("Remove: %s", err)
+			err = fmt.Errorf("Remove: %w", err)
 			return
 		}
 
 		_ = libio.RmdirEmptyAll(pkgImportPath)
+
+		if rmp != nil {
+			_ = env.runHooks(hookPost, hookOpRemove, rmp, rmp.Version,
+				rmp.Version)
+		}
 	}
 
+	env.printSkipped("Remove")
+
 	return nil
 }
 
@@ -766,6 +1280,12 @@ func (env *Env) removePackage(importPath string) (err error) {
 	env.removeRequiredBy(importPath)
 	env.removePkgFromDBByIdx(pkgIdx)
 
+	env.emit(Event{
+		Kind:       EventPackageRemoved,
+		ImportPath: pkg.ImportPath,
+		OldVersion: pkg.Version,
+	})
+
 	return
 }
 
@@ -809,8 +1329,8 @@ func (env *Env) Save(file string) (err error) {
 		}
 	}
 
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] Save >>>", file)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] Save >>>", file)
 	}
 
 	dir := filepath.Dir(file)
@@ -830,6 +1350,11 @@ func (env *Env) Save(file string) (err error) {
 		return err
 	}
 
+	env.emit(Event{
+		Kind: EventDatabaseSaved,
+		File: file,
+	})
+
 	return nil
 }
 
@@ -837,6 +1362,21 @@ func (env *Env) saveBeku() {
 	for _, exclude := range env.pkgsExclude {
 		env.db.Add(sectionBeku, "", keyExclude, exclude)
 	}
+	for _, rule := range env.urlRewrites {
+		env.db.Add(sectionBeku, "", keyRewrite, rule.String())
+	}
+	for _, tmpl := range env.compareURLs {
+		env.db.Add(sectionBeku, "", keyCompareURL, tmpl.String())
+	}
+	for _, allow := range env.licenseAllow {
+		env.db.Add(sectionBeku, "", keyLicAllow, allow)
+	}
+	if env.signature != nil {
+		env.db.Set(sectionBeku, "", keySignature, env.signature.String())
+		if len(env.signature.keyring) > 0 {
+			env.db.Set(sectionBeku, "", keyKeyring, env.signature.keyring)
+		}
+	}
 }
 
 func (env *Env) savePackages() {
@@ -847,7 +1387,19 @@ func (env *Env) savePackages() {
 		if len(pkg.RemoteBranch) > 0 {
 			env.db.Set(sectionPackage, pkg.ImportPath, keyRemoteBranch, pkg.RemoteBranch)
 		}
+		if len(pkg.UpstreamURL) > 0 {
+			env.db.Set(sectionPackage, pkg.ImportPath, keyUpstreamURL, pkg.UpstreamURL)
+		}
 		env.db.Set(sectionPackage, pkg.ImportPath, keyVersion, pkg.Version)
+		if len(pkg.CommitHash) > 0 {
+			env.db.Set(sectionPackage, pkg.ImportPath, keyCommit, pkg.CommitHash)
+		}
+		if len(pkg.TreeHash) > 0 {
+			env.db.Set(sectionPackage, pkg.ImportPath, keyTree, pkg.TreeHash)
+		}
+		if len(pkg.Signature) > 0 {
+			env.db.Set(sectionPackage, pkg.ImportPath, keySignature, pkg.Signature)
+		}
 
 		for _, dep := range pkg.Deps {
 			env.db.Add(sectionPackage, pkg.ImportPath, keyDeps, dep)
@@ -858,6 +1410,9 @@ func (env *Env) savePackages() {
 		for _, mis := range pkg.DepsMissing {
 			env.db.Add(sectionPackage, pkg.ImportPath, keyDepsMissing, mis)
 		}
+		for _, license := range pkg.Licenses {
+			env.db.Add(sectionPackage, pkg.ImportPath, keyLicense, license)
+		}
 	}
 }
 
@@ -868,12 +1423,14 @@ func (env *Env) String() string {
 	fmt.Fprintf(&buf, `
 [ENV]
              Prefix: %s
+             GOROOT: %s
+           Platform: %s_%s
             Dir bin: %s
             Dir pkg: %s
             Dir src: %s
        Dir root src: %s
   Standard Packages: %s
-`, env.prefix, env.dirBin, env.dirPkg, env.dirSrc, env.dirGoRootSrc, env.pkgsStd)
+`, env.prefix, env.goroot, env.goos, env.goarch, env.dirBin, env.dirPkg, env.dirSrc, env.dirGoRootSrc, env.pkgsStd)
 
 	for x := 0; x < len(env.pkgs); x++ {
 		fmt.Fprintf(&buf, "%s", env.pkgs[x].String())
@@ -891,9 +1448,9 @@ func (env *Env) String() string {
 // clean the directory first.
 func (env *Env) install(pkg *Package) (ok bool, err error) {
 	if !libio.IsDirEmpty(pkg.FullPath) {
-		fmt.Printf("[ENV] install >>> Directory %s is not empty.\n", pkg.FullPath)
+		env.log.Printf("[ENV] install >>> Directory %s is not empty.\n", pkg.FullPath)
 		if !env.NoConfirm {
-			ok = libio.ConfirmYesNo(os.Stdin, msgCleanDir, false)
+			ok = env.confirm(msgCleanDir, false)
 			if !ok {
 				return
 			}
@@ -901,7 +1458,12 @@ func (env *Env) install(pkg *Package) (ok bool, err error) {
 		_ = pkg.Remove()
 	}
 
-	err = pkg.Install()
+	err = env.runHooks(hookPre, hookOpSync, pkg, "", pkg.Version)
+	if err != nil {
+		return false, err
+	}
+
+	err = env.installPackage(pkg)
 	if err != nil {
 		_ = pkg.Remove()
 		return
@@ -913,11 +1475,21 @@ func (env *Env) install(pkg *Package) (ok bool, err error) {
 }
 
 func (env *Env) update(curPkg, newPkg *Package) (ok bool, err error) {
+	if len(newPkg.UpstreamURL) > 0 && curPkg.UpstreamURL != newPkg.UpstreamURL {
+		curPkg.UpstreamURL = newPkg.UpstreamURL
+		env.dirty = true
+	}
+
 	err = curPkg.FetchLatestVersion()
 	if err != nil {
 		return
 	}
 
+	err = curPkg.verifyHash()
+	if err != nil {
+		return
+	}
+
 	if len(newPkg.Version) == 0 {
 		newPkg.Version = curPkg.VersionNext
 		newPkg.isTag = curPkg.isTag
@@ -929,21 +1501,21 @@ func (env *Env) update(curPkg, newPkg *Package) (ok bool, err error) {
 		}
 	}
 
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] update >>>", newPkg)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] update >>>", newPkg)
 	}
 
 	if curPkg.IsEqual(newPkg) || !newPkg.IsNewer(curPkg) {
-		fmt.Println("[ENV] update >>> All package is up todate.")
+		env.log.Println("[ENV] update >>> All package is up todate.")
 		ok = true
 		return
 	}
 
-	fmt.Printf("[ENV] update >>> Updating package from,\n%s\nto,\n%s\n",
+	env.log.Printf("[ENV] update >>> Updating package from,\n%s\nto,\n%s\n",
 		curPkg.String(), newPkg.String())
 
 	if !env.NoConfirm {
-		ok = libio.ConfirmYesNo(os.Stdin, msgUpdateView, false)
+		ok = env.confirm(msgUpdateView, false)
 		if ok {
 			err = curPkg.CompareVersion(newPkg)
 			if err != nil {
@@ -953,13 +1525,43 @@ func (env *Env) update(curPkg, newPkg *Package) (ok bool, err error) {
 	}
 
 	if !env.NoConfirm {
-		ok = libio.ConfirmYesNo(os.Stdin, msgUpdateProceed, true)
+		ok = env.confirm(msgUpdateProceed, true)
 		if !ok {
 			return
 		}
 	}
 
+	// Verify the new version before running the hooks or stashing the
+	// local changes, so nothing is changed if its not signed.
+	err = curPkg.verifyRemote(newPkg.vcsRemoteURL(), newPkg.Version)
+	if errors.Is(err, ErrUnsigned) {
+		env.skipUnsigned(curPkg, "update", err)
+		env.printSkipped("update")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = env.runHooks(hookPre, hookOpUpdate, curPkg, curPkg.Version,
+		newPkg.Version)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err = env.checkDirty(curPkg, "update")
+	if !ok || err != nil {
+		env.printSkipped("update")
+		return false, err
+	}
+
 	err = curPkg.Update(newPkg)
+	env.restoreDirty(curPkg, "update")
+	if errors.Is(err, ErrUnsigned) {
+		env.skipUnsigned(curPkg, "update", err)
+		env.printSkipped("update")
+		return false, nil
+	}
 	if err != nil {
 		return
 	}
@@ -975,20 +1577,29 @@ func (env *Env) installMissing(pkg *Package) (err error) {
 		return
 	}
 
-	fmt.Printf("[ENV] installMissing %s >>> %s\n", pkg.ImportPath, pkg.DepsMissing)
+	env.log.Printf("[ENV] installMissing %s >>> %s\n", pkg.ImportPath, pkg.DepsMissing)
 
 	for _, misImportPath := range pkg.DepsMissing {
+		if env.canceled("installMissing") != nil {
+			return
+		}
+
 		_, misPkg := env.GetPackageFromDB(misImportPath, "")
 		if misPkg != nil {
 			continue
 		}
 
-		fmt.Printf("[ENV] installMissing %s >>> %s\n", pkg.ImportPath,
+		env.log.Printf("[ENV] installMissing %s >>> %s\n", pkg.ImportPath,
 			misImportPath)
 
-		err = env.Sync(misImportPath, misImportPath)
+		err = env.sync(misImportPath, misImportPath)
+		if errors.Is(err, ErrExcluded) {
+			env.log.Printf("[ENV] installMissing >>> %s\n", err)
+			err = nil
+			continue
+		}
 		if err != nil {
-			fmt.Fprintf(defStderr, "[ENV] installMissing >>> %s\n", err)
+			env.log.Warnf("[ENV] installMissing >>> %s\n", err)
 			continue
 		}
 	}
@@ -1002,8 +1613,8 @@ func (env *Env) installMissing(pkg *Package) (err error) {
 func (env *Env) updateMissing(newPkg *Package, addAsDep bool) {
 	var updated bool
 
-	if debug.Value >= 1 {
-		fmt.Println("[ENV] updateMissing >>>", newPkg.ImportPath)
+	if env.log.IsVerbose() {
+		env.log.Println("[ENV] updateMissing >>>", newPkg.ImportPath)
 	}
 
 	for x := 0; x < len(env.pkgs); x++ {
@@ -1029,14 +1640,28 @@ func (env *Env) updateMissing(newPkg *Package, addAsDep bool) {
 // Sync will download and install a package including their dependencies. If
 // the importPath is defined, it will be downloaded into that directory.
 func (env *Env) Sync(pkgName, importPath string) (err error) {
+	return env.SyncContext(context.Background(), pkgName, importPath)
+}
+
+// SyncContext is like Sync but it stop when the context ctx is done.
+func (env *Env) SyncContext(ctx context.Context, pkgName, importPath string) (err error) {
+	defer env.withContext(ctx)()
+
+	err = env.sync(pkgName, importPath)
+
+	return env.contextErr("Sync", err)
+}
+
+func (env *Env) sync(pkgName, importPath string) (err error) {
 	err = ErrPackageName
 
 	if len(pkgName) == 0 {
 		return
 	}
 	var (
-		ok      bool
-		version string
+		ok         bool
+		version    string
+		oldVersion string
 	)
 
 	pkgName, version = parsePkgVersion(pkgName)
@@ -1049,16 +1674,22 @@ func (env *Env) Sync(pkgName, importPath string) (err error) {
 	}
 
 	if env.IsExcluded(pkgName) || env.IsExcluded(importPath) {
-		fmt.Printf(errExcluded, pkgName)
-		err = nil
-		return
+		return &PackageError{
+			ImportPath: pkgName,
+			Op:         "Sync",
+			Err:        ErrExcluded,
+		}
 	}
 
-	newPkg, err := NewPackage(env.dirSrc, pkgName, importPath)
+	newPkg, err := env.resolvePackage(pkgName, importPath)
 	if err != nil {
 		return
 	}
 
+	if pkgName != importPath {
+		env.resolveUpstream(newPkg)
+	}
+
 	if len(version) > 0 {
 		newPkg.Version = version
 		newPkg.isTag = IsTagVersion(version)
@@ -1067,7 +1698,9 @@ func (env *Env) Sync(pkgName, importPath string) (err error) {
 	// Check if package already installed, by checking out from database.
 	_, curPkg := env.GetPackageFromDB(newPkg.ImportPath, newPkg.RemoteURL)
 	if curPkg != nil {
+		oldVersion = curPkg.Version
 		newPkg.RemoteURL = curPkg.RemoteURL
+		curPkg.mirrorURL = newPkg.mirrorURL
 		ok, err = env.update(curPkg, newPkg)
 	} else {
 		ok, err = env.install(newPkg)
@@ -1084,135 +1717,395 @@ func (env *Env) Sync(pkgName, importPath string) (err error) {
 		env.dirty = true
 	}
 
-	err = env.postSync(curPkg)
+	err = env.postSync(curPkg, oldVersion)
 	if err != nil {
 		return err
 	}
 
+	if env.TestDependents && len(oldVersion) > 0 && oldVersion != curPkg.Version {
+		err = env.testDependents(map[string]string{
+			curPkg.ImportPath: oldVersion,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // SyncMany packages at once.
 func (env *Env) SyncMany(pkgs []string) (err error) {
+	return env.SyncManyContext(context.Background(), pkgs)
+}
+
+// SyncManyContext is like SyncMany but it stop when the context ctx is
+// done.
+// The packages that has been synced before the context is done are kept in
+// database.
+func (env *Env) SyncManyContext(ctx context.Context, pkgs []string) (err error) {
+	var errs PackageErrors
+
+	defer env.withContext(ctx)()
+
 	for _, pkg := range pkgs {
-		err = env.Sync(pkg, "")
+		err = env.canceled("SyncMany")
 		if err != nil {
-			return
+			return err
+		}
+
+		err = env.sync(pkg, "")
+		if err == nil {
+			continue
+		}
+		if !env.KeepGoing {
+			return env.contextErr("SyncMany", err)
+		}
+
+		err = env.collectError(&errs, "SyncMany", pkg, err)
+		if err != nil {
+			return err
 		}
 	}
 
-	return
+	env.printFailed("SyncMany", errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
 }
 
 // SyncAll packages into latest version (tag or commit).
 func (env *Env) SyncAll() (err error) {
+	return env.SyncAllContext(context.Background())
+}
+
+// SyncAllContext is like SyncAll but it stop when the context ctx is done.
+//
+// The context is checked before each package is fetched, checked out, and
+// installed.
+// The packages that has been checked out before the context is done are
+// marked as updated, so saving the database keep it consistent with the
+// "src" directory.
+func (env *Env) SyncAllContext(ctx context.Context) (err error) {
 	var (
+		errs        PackageErrors
 		countUpdate int
 		buf         bytes.Buffer
+		bufBreaking bytes.Buffer
 	)
 
+	defer env.withContext(ctx)()
+
 	format := fmt.Sprintf("%%-%ds  %%-12s  %%-12s %%s\n", env.fmtMaxPath)
 	fmt.Fprintf(&buf, "[ENV] SyncAll >>> The following packages will be updated,\n\n")
 	fmt.Fprintf(&buf, format+"\n", "ImportPath", "Old Version",
 		"New Version", "Compare URL")
 
-	fmt.Println("[ENV] SyncAll >>> Updating all packages ...")
+	env.log.Println("[ENV] SyncAll >>> Updating all packages ...")
 
 	for _, pkg := range env.pkgs {
-		if libio.IsDirEmpty(pkg.FullPath) {
-			fmt.Printf("[ENV] SyncAll %s >>> Installing\n",
-				pkg.ImportPath)
-
-			err = pkg.Install()
-			if err != nil {
-				_ = pkg.Remove()
-				return
-			}
+		err = env.canceled("SyncAll")
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("[ENV] SyncAll %s >>> Current version is %s\n",
-			pkg.ImportPath, pkg.Version)
-
-		err = pkg.FetchLatestVersion()
+		err = env.fetch(pkg)
 		if err != nil {
-			return
+			pkg.VersionNext = pkg.Version
+			err = env.collectError(&errs, "SyncAll", pkg.ImportPath, err)
+			if err != nil {
+				return err
+			}
+			continue
 		}
 
 		if pkg.Version >= pkg.VersionNext {
-			fmt.Printf("[ENV] SyncAll %s >>> No update.\n\n",
+			env.log.Printf("[ENV] SyncAll %s >>> No update.\n\n",
 				pkg.ImportPath)
 			pkg.VersionNext = pkg.Version
 			continue
 		}
 
-		fmt.Printf("[ENV] SyncAll %s >>> Latest version is %s\n\n",
+		err = pkg.verifySignature(pkg.VersionNext)
+		if errors.Is(err, ErrUnsigned) {
+			env.skipUnsigned(pkg, "SyncAll", err)
+			pkg.VersionNext = pkg.Version
+			continue
+		}
+		if err != nil {
+			pkg.VersionNext = pkg.Version
+			err = env.collectError(&errs, "SyncAll", pkg.ImportPath, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		env.log.Printf("[ENV] SyncAll %s >>> Latest version is %s\n\n",
 			pkg.ImportPath, pkg.VersionNext)
 
-		compareURL := GetCompareURL(pkg.RemoteURL, pkg.Version,
-			pkg.VersionNext)
+		compareURL := env.compareURLs.compareURL(pkg.RemoteURL,
+			pkg.Version, pkg.VersionNext)
+
+		if env.CheckAPI {
+			changes, errAPI := env.apiBreaking(pkg)
+			if errAPI != nil {
+				env.log.Warnf("[ENV] SyncAll %s >>> %s\n",
+					pkg.ImportPath, errAPI)
+			}
+			if len(changes) > 0 {
+				compareURL += " (breaking)"
+				writeAPIChanges(&bufBreaking, pkg, changes)
+			}
+		}
 
 		fmt.Fprintf(&buf, format, pkg.ImportPath, pkg.Version,
 			pkg.VersionNext, compareURL)
 
+		env.emit(Event{
+			Kind:       EventVersionPlanned,
+			ImportPath: pkg.ImportPath,
+			OldVersion: pkg.Version,
+			NewVersion: pkg.VersionNext,
+		})
+
 		countUpdate++
 	}
 
 	if countUpdate == 0 {
-		fmt.Println("[ENV] SyncAll >>> All packages are up to date.")
-		return
+		if len(errs) > 0 {
+			env.printFailed("SyncAll", errs)
+			return errs
+		}
+		env.log.Println("[ENV] SyncAll >>> All packages are up to date.")
+		return nil
 	}
 
-	fmt.Println(buf.String())
+	env.log.Println(buf.String())
+
+	if bufBreaking.Len() > 0 {
+		env.log.Println("[ENV] SyncAll >>> The following updates contains breaking API changes,")
+		env.log.Println(bufBreaking.String())
+	}
+
+	if len(env.ChangelogFile) > 0 {
+		err = env.writeChangelog(env.ChangelogFile)
+		if err != nil {
+			return fmt.Errorf("SyncAll: %w", err)
+		}
+		env.log.Printf("[ENV] SyncAll >>> Changelog is written to %s\n\n",
+			env.ChangelogFile)
+	}
 
 	if !env.NoConfirm {
-		ok := libio.ConfirmYesNo(os.Stdin, msgContinue, false)
+		ok := env.confirm(msgContinue, false)
 		if !ok {
-			return
+			return nil
 		}
 	}
 
+	updated := make(map[string]string)
+
 	for _, pkg := range env.pkgs {
-		err = pkg.CheckoutVersion(pkg.VersionNext)
+		err = env.canceled("SyncAll")
 		if err != nil {
-			return
+			return err
 		}
+
+		// Only the package that will be changed is checked for local
+		// changes and checked out.
 		if pkg.Version != pkg.VersionNext {
+			ok := true
+			err = env.runHooks(hookPre, hookOpUpdate, pkg,
+				pkg.Version, pkg.VersionNext)
+			if err == nil {
+				ok, err = env.checkDirty(pkg, "SyncAll")
+			}
+			if err == nil && ok {
+				err = pkg.CheckoutVersion(pkg.VersionNext)
+				env.restoreDirty(pkg, "SyncAll")
+			}
+			if err != nil {
+				pkg.VersionNext = pkg.Version
+				err = env.collectError(&errs, "SyncAll",
+					pkg.ImportPath, err)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if !ok {
+				pkg.VersionNext = pkg.Version
+				continue
+			}
+
+			updated[pkg.ImportPath] = pkg.Version
+
+			env.emit(Event{
+				Kind:       EventCheckoutDone,
+				ImportPath: pkg.ImportPath,
+				OldVersion: pkg.Version,
+				NewVersion: pkg.VersionNext,
+			})
+
 			pkg.Version = pkg.VersionNext
 			pkg.state = packageStateDirty
 		}
+
+		// Mark the database as dirty on each package, so the
+		// packages that has been checked out are saved even if the
+		// next one is canceled.
+		env.dirty = true
+
+		err = pkg.resolveHash()
+		if err != nil {
+			err = env.collectError(&errs, "SyncAll", pkg.ImportPath, err)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	env.dirty = true
 
 	for _, pkg := range env.pkgs {
+		err = env.canceled("SyncAll")
+		if err != nil {
+			return err
+		}
+
 		if pkg.state&packageStateDirty > 0 {
-			_ = env.postSync(pkg)
-			// Ignore error. Go install may failed due to missing
-			// dependencies.
+			oldVersion, ok := updated[pkg.ImportPath]
+			if !ok {
+				oldVersion = pkg.Version
+			}
+			err = env.postSync(pkg, oldVersion)
+			if err != nil {
+				err = env.collectError(&errs, "SyncAll",
+					pkg.ImportPath, err)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	env.printSkipped("SyncAll")
+	env.printFailed("SyncAll", errs)
+
+	env.log.Println("[ENV] SyncAll >>> Update completed.")
+
+	if env.TestDependents {
+		err = env.testDependents(updated)
+		if err != nil {
+			return fmt.Errorf("SyncAll: %w", err)
 		}
 	}
 
-	fmt.Println("[ENV] SyncAll >>> Update completed.")
+	if len(errs) > 0 {
+		return errs
+	}
 
 	return nil
 }
 
-func (env *Env) postSync(pkg *Package) (err error) {
-	fmt.Printf("\n[ENV] postSync %s\n", pkg.ImportPath)
+// fetch install the package if its directory is empty, and then fetch the
+// latest version of package and verify that the current version has not
+// been moved.
+func (env *Env) fetch(pkg *Package) (err error) {
+	err = env.useMirror(pkg)
+	if err != nil {
+		return err
+	}
+
+	if libio.IsDirEmpty(pkg.FullPath) {
+		env.log.Printf("[ENV] SyncAll %s >>> Installing\n",
+			pkg.ImportPath)
+
+		err = env.installPackage(pkg)
+		if err != nil {
+			_ = pkg.Remove()
+			return err
+		}
+	}
+
+	env.log.Printf("[ENV] SyncAll %s >>> Current version is %s\n",
+		pkg.ImportPath, pkg.Version)
+
+	env.emit(Event{
+		Kind:       EventFetchStarted,
+		ImportPath: pkg.ImportPath,
+		OldVersion: pkg.Version,
+	})
+
+	err = pkg.FetchLatestVersion()
+
+	env.emit(Event{
+		Kind:       EventFetchFinished,
+		ImportPath: pkg.ImportPath,
+		OldVersion: pkg.Version,
+		NewVersion: pkg.VersionNext,
+		Err:        err,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return pkg.verifyHash()
+}
+
+// postSync update the missing dependencies and install the package after
+// its synced.
+// If oldVersion is empty, the package is new one and the post hooks for
+// "sync" operation are run; otherwise the post hooks for "update" operation
+// are run.
+// The pre hooks are run by install, update, and SyncAll, before the package
+// version is changed.
+func (env *Env) postSync(pkg *Package, oldVersion string) (err error) {
+	op := hookOpSync
+	if len(oldVersion) > 0 {
+		op = hookOpUpdate
+	}
+
+	env.log.Printf("\n[ENV] postSync %s\n", pkg.ImportPath)
 	// Update missing packages.
 	env.updateMissing(pkg, true)
 
 	err = env.build(pkg)
 	if err != nil {
+		env.emit(Event{
+			Kind:       EventBuildResult,
+			ImportPath: pkg.ImportPath,
+			NewVersion: pkg.Version,
+			Err:        err,
+		})
 		return
 	}
 
 	// Run `go install` only if no missing package.
+	var errInstall error
 	if len(pkg.DepsMissing) == 0 {
-		_ = pkg.GoInstall(env.path)
+		errInstall = pkg.GoInstall(env.path)
+	}
+
+	env.emit(Event{
+		Kind:       EventBuildResult,
+		ImportPath: pkg.ImportPath,
+		NewVersion: pkg.Version,
+		Err:        errInstall,
+	})
+
+	if errInstall != nil && env.KeepGoing {
+		return fmt.Errorf("postSync: %w", errInstall)
 	}
 
-	fmt.Println("[ENV] postSync >>> Package installed:\n", pkg)
+	_ = env.runHooks(hookPost, op, pkg, oldVersion, pkg.Version)
+
+	env.log.Println("[ENV] postSync >>> Package installed:\n", pkg)
 
 	return
 }
@@ -5,6 +5,7 @@
 package beku
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -108,6 +109,8 @@ func TestEnvExclude(t *testing.T) {
 				"github.com/alecthomas/gometalinter",
 			},
 			state:   packageStateDirty,
+			log:     testEnv.log,
+			goEnv:   testEnv.goEnviron(),
 			vcsMode: VCSModeGit,
 		},
 		expMissing: []string{
@@ -142,6 +145,8 @@ func TestEnvExclude(t *testing.T) {
 			RemoteBranch: "master",
 			Version:      "b2c8fd7",
 			state:        packageStateLoad,
+			log:          testEnv.log,
+			goEnv:        testEnv.goEnviron(),
 			vcsMode:      VCSModeGit,
 		},
 		expMissing: []string{
@@ -236,6 +241,8 @@ func TestEnvGetPackageFromDB(t *testing.T) {
 			Version:      "0725fc6",
 			vcsMode:      VCSModeGit,
 			state:        packageStateLoad,
+			log:          testEnv.log,
+			goEnv:        testEnv.goEnviron(),
 			Deps: []string{
 				"github.com/stretchr/testify",
 				"gotest.tools",
@@ -256,6 +263,8 @@ func TestEnvGetPackageFromDB(t *testing.T) {
 			isTag:        true,
 			vcsMode:      VCSModeGit,
 			state:        packageStateLoad,
+			log:          testEnv.log,
+			goEnv:        testEnv.goEnviron(),
 			Deps: []string{
 				"github.com/pkg/errors",
 				"golang.org/x/tools",
@@ -505,7 +514,11 @@ func TestEnvScan(t *testing.T) {
 			RemoteURL:    testGitRepoSrcLocal,
 			RemoteBranch: "master",
 			Version:      "v0.2.0",
+			CommitHash:   "0d58f3dd6d960165a90824bc74ebea96368c7c04",
+			TreeHash:     "9ff5049ab658326cc101673605524eb154a10721",
 			isTag:        true,
+			log:          testEnv.log,
+			goEnv:        testEnv.goEnviron(),
 			vcsMode:      VCSModeGit,
 			state:        packageStateNew,
 		}},
@@ -525,6 +538,97 @@ func TestEnvScan(t *testing.T) {
 	}
 }
 
+// testRescanEnv create new environment using prefix as GOPATH, load the
+// database if its not the first time, rescan the packages, and save the
+// database.
+func testRescanEnv(t *testing.T, prefix string, firstTime bool) (env *Env) {
+	var out bytes.Buffer
+
+	env, err := NewEnvironment(WithPrefix(prefix), WithOutput(&out, &out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.NoConfirm = true
+
+	if !firstTime {
+		err = env.Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = env.Rescan(firstTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Save("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestEnvRescanThenFreeze(t *testing.T) {
+	var (
+		out       bytes.Buffer
+		prefix    = t.TempDir()
+		remoteDir = filepath.Join(prefix, "remote.git")
+		repoDir   = filepath.Join(prefix, dirSrc, "example.com", "repo")
+	)
+
+	err := os.MkdirAll(remoteDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGit(t, remoteDir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--bare", "--initial-branch="+gitDefBranch)
+	testGit(t, prefix, "2018-01-01T00:00:00Z", "clone", "--quiet",
+		remoteDir, repoDir)
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "first")
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "tag", "v1.0.0")
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "push", "--quiet",
+		"--tags", "origin", gitDefBranch)
+
+	testRescanEnv(t, prefix, true)
+
+	// Update the package outside of beku and rescan it.
+	testGit(t, repoDir, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "second")
+	testGit(t, repoDir, "2018-01-02T00:00:00Z", "tag", "v1.1.0")
+	testGit(t, repoDir, "2018-01-02T00:00:00Z", "push", "--quiet",
+		"--tags", "origin", gitDefBranch)
+
+	env := testRescanEnv(t, prefix, false)
+
+	pkg := env.pkgs[0]
+	test.Assert(t, "Version", "v1.1.0", pkg.Version)
+
+	commit, err := pkg.gitRevParse("v1.1.0^{commit}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "CommitHash", commit, pkg.CommitHash)
+
+	env, err = NewEnvironment(WithPrefix(prefix), WithOutput(&out, &out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestEnvSync(t *testing.T) {
 	cases := []struct {
 		desc       string
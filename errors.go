@@ -0,0 +1,104 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PackageError define an error that happened when running operation Op on
+// package ImportPath.
+// The cause of error can be checked using errors.Is or errors.As, for
+// example,
+//
+//	var pkgErr *beku.PackageError
+//	if errors.As(err, &pkgErr) && errors.Is(err, beku.ErrDirty) {
+//		fmt.Println(pkgErr.ImportPath, "has local changes")
+//	}
+type PackageError struct {
+	ImportPath string
+	Op         string
+	Err        error
+}
+
+// Error return the error message in the form of "<Op> <ImportPath>: <Err>".
+func (pkgErr *PackageError) Error() string {
+	return pkgErr.Op + " " + pkgErr.ImportPath + ": " + pkgErr.Err.Error()
+}
+
+// Unwrap return the cause of error.
+func (pkgErr *PackageError) Unwrap() error {
+	return pkgErr.Err
+}
+
+// PackageErrors contains the errors of all packages that failed during
+// operation that continue after the first error, for example SyncAll and
+// Freeze.
+type PackageErrors []*PackageError
+
+// Error return the error message of each package, separated by new line.
+func (errs PackageErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, pkgErr := range errs {
+		msgs = append(msgs, pkgErr.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap return the error of each package, so errors.Is and errors.As can
+// match any of them.
+func (errs PackageErrors) Unwrap() []error {
+	list := make([]error, 0, len(errs))
+	for _, pkgErr := range errs {
+		list = append(list, pkgErr)
+	}
+	return list
+}
+
+// collectError append the error err on package importPath into errs.
+// If the environment context is done, it will return the context error, so
+// the operation can stop immediately; otherwise it will return nil and the
+// operation continue with the next package.
+func (env *Env) collectError(errs *PackageErrors, op, importPath string, err error) error {
+	errCtx := env.canceled(op)
+	if errCtx != nil {
+		return errCtx
+	}
+
+	env.log.Warnf("[ENV] %s %s >>> %s\n", op, importPath, err)
+
+	*errs = append(*errs, &PackageError{
+		ImportPath: importPath,
+		Op:         op,
+		Err:        err,
+	})
+
+	return nil
+}
+
+// printFailed print the summary of packages that failed on operation op and
+// the reason, one package per line.
+func (env *Env) printFailed(op string, errs PackageErrors) {
+	if len(errs) == 0 {
+		return
+	}
+
+	maxPath := len("ImportPath")
+	for _, pkgErr := range errs {
+		if len(pkgErr.ImportPath) > maxPath {
+			maxPath = len(pkgErr.ImportPath)
+		}
+	}
+
+	format := fmt.Sprintf("%%-%ds  %%s\n", maxPath)
+
+	env.log.Warnf("\n[ENV] %s >>> The following packages are failed,\n\n", op)
+	env.log.Warnf(format, "ImportPath", "Reason")
+	for _, pkgErr := range errs {
+		env.log.Warnf(format, pkgErr.ImportPath, pkgErr.Err)
+	}
+	env.log.Warnf("\n")
+}
@@ -0,0 +1,134 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestPackageError(t *testing.T) {
+	var (
+		errs PackageErrors = []*PackageError{{
+			ImportPath: "github.com/shuLhan/share",
+			Op:         "Sync",
+			Err:        ErrExcluded,
+		}, {
+			ImportPath: "github.com/shuLhan/beku",
+			Op:         "Freeze",
+			Err:        ErrDirty,
+		}}
+		err    error = errs
+		pkgErr *PackageError
+	)
+
+	test.Assert(t, "Error", "Sync github.com/shuLhan/share: package is in excluded list\n"+
+		"Freeze github.com/shuLhan/beku: working tree has local changes",
+		err.Error())
+	test.Assert(t, "errors.Is ErrExcluded", true, errors.Is(err, ErrExcluded))
+	test.Assert(t, "errors.Is ErrDirty", true, errors.Is(err, ErrDirty))
+	test.Assert(t, "errors.Is ErrVCS", false, errors.Is(err, ErrVCS))
+	test.Assert(t, "errors.As", true, errors.As(err, &pkgErr))
+	test.Assert(t, "errors.As ImportPath", "github.com/shuLhan/share",
+		pkgErr.ImportPath)
+}
+
+func TestEnvFreezeCollectErrors(t *testing.T) {
+	dirSrc := t.TempDir()
+
+	env := &Env{
+		dirSrc:    dirSrc,
+		KeepGoing: true,
+		pkgs: []*Package{{
+			ImportPath: "example.com/a",
+			FullPath:   filepath.Join(dirSrc, "example.com/a"),
+		}, {
+			ImportPath: "example.com/b",
+			FullPath:   filepath.Join(dirSrc, "example.com/b"),
+		}},
+	}
+
+	for _, pkg := range env.pkgs {
+		testWriteFiles(t, pkg.FullPath, map[string]string{
+			"main.go": "package main\n",
+		})
+	}
+
+	err := env.Freeze()
+
+	var errs PackageErrors
+	test.Assert(t, "errors.As PackageErrors", true, errors.As(err, &errs))
+	test.Assert(t, "number of errors", 2, len(errs))
+	test.Assert(t, "errors.Is ErrDirNotEmpty", true,
+		errors.Is(err, ErrDirNotEmpty))
+
+	for x, pkg := range env.pkgs {
+		test.Assert(t, "ImportPath", pkg.ImportPath, errs[x].ImportPath)
+		test.Assert(t, "Op", "Freeze", errs[x].Op)
+	}
+
+	env.KeepGoing = false
+
+	err = env.Freeze()
+
+	var pkgErr *PackageError
+	test.Assert(t, "without KeepGoing errors.As PackageErrors", false,
+		errors.As(err, &errs))
+	test.Assert(t, "without KeepGoing errors.As PackageError", true,
+		errors.As(err, &pkgErr))
+	test.Assert(t, "stop at first package", "example.com/a",
+		pkgErr.ImportPath)
+}
+
+func TestEnvSyncExcluded(t *testing.T) {
+	env := &Env{
+		pkgsExclude: []string{"github.com/shuLhan/share"},
+	}
+
+	err := env.Sync("github.com/shuLhan/share", "")
+
+	var pkgErr *PackageError
+	test.Assert(t, "errors.As", true, errors.As(err, &pkgErr))
+	test.Assert(t, "errors.Is ErrExcluded", true, errors.Is(err, ErrExcluded))
+	test.Assert(t, "Op", "Sync", pkgErr.Op)
+}
+
+func TestEnvSyncManyKeepGoing(t *testing.T) {
+	var (
+		errOut bytes.Buffer
+		env    = &Env{
+			pkgsExclude: []string{"example.com/a", "example.com/b"},
+			log:         NewLogger(nil, &errOut, LogQuiet),
+		}
+		pkgs = []string{"example.com/a", "example.com/b"}
+		errs PackageErrors
+	)
+
+	err := env.SyncMany(pkgs)
+	test.Assert(t, "without KeepGoing errors.As PackageErrors", false,
+		errors.As(err, &errs))
+	test.Assert(t, "without KeepGoing errors.Is ErrExcluded", true,
+		errors.Is(err, ErrExcluded))
+
+	env.KeepGoing = true
+
+	err = env.SyncMany(pkgs)
+	test.Assert(t, "errors.As PackageErrors", true, errors.As(err, &errs))
+	test.Assert(t, "number of errors", 2, len(errs))
+	test.Assert(t, "errors.Is ErrExcluded", true, errors.Is(err, ErrExcluded))
+
+	test.Assert(t, "summary", true, strings.Contains(errOut.String(), `
+[ENV] SyncMany >>> The following packages are failed,
+
+ImportPath     Reason
+example.com/a  Sync example.com/a: package is in excluded list
+example.com/b  Sync example.com/b: package is in excluded list
+`))
+}
@@ -0,0 +1,106 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+// EventKind define the type of event that is emitted by environment.
+type EventKind int
+
+// List of event kinds.
+const (
+	// EventPackageDiscovered is emitted when new package is found in
+	// "src" directory during Scan.
+	EventPackageDiscovered EventKind = iota + 1
+
+	// EventFetchStarted is emitted before the latest version of package
+	// is fetched from remote.
+	EventFetchStarted
+
+	// EventFetchFinished is emitted after the latest version of package
+	// is fetched.
+	// The NewVersion is the latest version and the Err is set if fetch
+	// failed.
+	EventFetchFinished
+
+	// EventVersionPlanned is emitted when the package will be changed
+	// from OldVersion to NewVersion.
+	EventVersionPlanned
+
+	// EventCheckoutDone is emitted after the package source has been
+	// checked out to NewVersion.
+	EventCheckoutDone
+
+	// EventBuildResult is emitted after the package dependencies are
+	// scanned and the package is installed.
+	// The Err is set if build or install failed.
+	EventBuildResult
+
+	// EventPackageRemoved is emitted after the package source and
+	// installed archives are removed.
+	EventPackageRemoved
+
+	// EventDatabaseSaved is emitted after the database has been written
+	// to File.
+	EventDatabaseSaved
+)
+
+// String return the name of event kind.
+func (kind EventKind) String() string {
+	switch kind {
+	case EventPackageDiscovered:
+		return "package-discovered"
+	case EventFetchStarted:
+		return "fetch-started"
+	case EventFetchFinished:
+		return "fetch-finished"
+	case EventVersionPlanned:
+		return "version-planned"
+	case EventCheckoutDone:
+		return "checkout-done"
+	case EventBuildResult:
+		return "build-result"
+	case EventPackageRemoved:
+		return "package-removed"
+	case EventDatabaseSaved:
+		return "database-saved"
+	}
+	return "unknown"
+}
+
+// Event contains the information of lifecycle event on package or
+// database.
+// The fields that are not relevant to the event kind are empty.
+type Event struct {
+	Kind       EventKind
+	ImportPath string
+	OldVersion string
+	NewVersion string
+	File       string
+	Err        error
+}
+
+// Observer define an interface to receive the events from environment, for
+// example to show progress or to write audit logs.
+// The events are emitted synchronously, in the same goroutine as the
+// operation.
+type Observer interface {
+	// OnEvent is called for each event.
+	OnEvent(ev Event)
+}
+
+// ObserverFunc is an adapter to use ordinary function as Observer.
+type ObserverFunc func(ev Event)
+
+// OnEvent call fn(ev).
+func (fn ObserverFunc) OnEvent(ev Event) {
+	fn(ev)
+}
+
+// emit send the event to environment observer, if its set.
+func (env *Env) emit(ev Event) {
+	if env.Observer == nil {
+		return
+	}
+	env.Observer.OnEvent(ev)
+}
@@ -0,0 +1,85 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestEventKindString(t *testing.T) {
+	cases := []struct {
+		kind EventKind
+		exp  string
+	}{{
+		kind: EventPackageDiscovered,
+		exp:  "package-discovered",
+	}, {
+		kind: EventBuildResult,
+		exp:  "build-result",
+	}, {
+		kind: EventDatabaseSaved,
+		exp:  "database-saved",
+	}, {
+		exp: "unknown",
+	}}
+
+	for _, c := range cases {
+		test.Assert(t, "String", c.exp, c.kind.String())
+	}
+}
+
+func TestEnvEvents(t *testing.T) {
+	var got []Event
+
+	pkg := testCreateDirtyRepo(t)
+	dirSrc := filepath.Dir(pkg.FullPath)
+	file := filepath.Join(t.TempDir(), "beku.db")
+
+	env := &Env{
+		dirSrc:    dirSrc,
+		dirPkg:    t.TempDir(),
+		NoConfirm: true,
+		Observer: ObserverFunc(func(ev Event) {
+			got = append(got, ev)
+		}),
+	}
+
+	err := env.newPackage(pkg.FullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "pkgs length", 1, len(env.pkgs))
+
+	version := env.pkgs[0].Version
+
+	err = env.Remove("repo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Save(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Event{{
+		Kind:       EventPackageDiscovered,
+		ImportPath: "repo",
+		NewVersion: version,
+	}, {
+		Kind:       EventPackageRemoved,
+		ImportPath: "repo",
+		OldVersion: version,
+	}, {
+		Kind: EventDatabaseSaved,
+		File: file,
+	}}
+
+	test.Assert(t, "events", exp, got)
+}
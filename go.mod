@@ -3,6 +3,7 @@ module github.com/shuLhan/beku
 go 1.18
 
 require (
+	github.com/klauspost/compress v1.16.7
 	github.com/shuLhan/share v0.44.0
 	golang.org/x/tools v0.1.12
 )
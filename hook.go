@@ -0,0 +1,226 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+// List of hook operations, the value of "operation" in hook file.
+const (
+	hookOpFreeze = "freeze"
+	hookOpRemove = "remove"
+	hookOpSync   = "sync"
+	hookOpUpdate = "update"
+)
+
+// List of hook time, the value of "when" in hook file.
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+)
+
+const (
+	dirHooks = "hooks"
+	hookExt  = ".hook"
+
+	sectionHookTrigger = "trigger"
+	sectionHookAction  = "action"
+
+	keyHookOperation = "operation"
+	keyHookTarget    = "target"
+	keyHookWhen      = "when"
+	keyHookExec      = "exec"
+)
+
+// hook define the command that is run before or after operation on package.
+//
+// The hook is defined in file with ".hook" extension inside the
+// "{prefix}/var/beku/hooks" directory, for example
+//
+//	[trigger]
+//	operation = sync
+//	operation = update
+//	target = github.com/shuLhan/*
+//
+//	[action]
+//	when = post
+//	exec = make -C "$BEKU_PACKAGE_DIR" install
+//
+// The hook is triggered if one of operation and one of target glob match
+// the operation and package import path.
+// If no target is defined, the hook match all packages.
+type hook struct {
+	name       string
+	when       string
+	exec       string
+	operations []string
+	targets    []string
+}
+
+// loadHooks load all hooks in directory dir, ordered by file name.
+// If the directory does not exist, it will return empty hooks.
+func loadHooks(dir string) (hooks []*hook, err error) {
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("loadHooks: %w", err)
+	}
+
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != hookExt {
+			continue
+		}
+
+		h, err := newHook(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("loadHooks: %w", err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	sort.Slice(hooks, func(x, y int) bool {
+		return hooks[x].name < hooks[y].name
+	})
+
+	return hooks, nil
+}
+
+// newHook create new hook from file.
+func newHook(file string) (h *hook, err error) {
+	cfg, err := ini.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	h = &hook{
+		name:       strings.TrimSuffix(filepath.Base(file), hookExt),
+		operations: cfg.Gets(sectionHookTrigger, "", keyHookOperation),
+		targets:    cfg.Gets(sectionHookTrigger, "", keyHookTarget),
+	}
+	h.when, _ = cfg.Get(sectionHookAction, "", keyHookWhen, "")
+	h.exec, _ = cfg.Get(sectionHookAction, "", keyHookExec, "")
+
+	if len(h.operations) == 0 {
+		return nil, fmt.Errorf("%s: missing operation", file)
+	}
+	for _, op := range h.operations {
+		switch op {
+		case hookOpFreeze, hookOpRemove, hookOpSync, hookOpUpdate:
+		default:
+			return nil, fmt.Errorf("%s: unknown operation %q", file, op)
+		}
+	}
+	for _, target := range h.targets {
+		_, err = path.Match(target, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid target %q: %w", file,
+				target, err)
+		}
+	}
+	if h.when != hookPre && h.when != hookPost {
+		return nil, fmt.Errorf("%s: invalid when %q", file, h.when)
+	}
+	if len(h.exec) == 0 {
+		return nil, fmt.Errorf("%s: missing exec", file)
+	}
+
+	return h, nil
+}
+
+// isMatch will return true if the hook is triggered by operation op on
+// package importPath.
+func (h *hook) isMatch(op, importPath string) bool {
+	var found bool
+	for _, hookOp := range h.operations {
+		if hookOp == op {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if len(h.targets) == 0 {
+		return true
+	}
+	for _, target := range h.targets {
+		ok, _ := path.Match(target, importPath)
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks run all hooks that match the time when, operation op, and the
+// package import path.
+//
+// The hook command is run using "sh -c" with the following environment
+// variables: BEKU_HOOK, BEKU_OPERATION, BEKU_PACKAGE, BEKU_PACKAGE_DIR,
+// BEKU_OLD_VERSION, and BEKU_NEW_VERSION.
+// The pre hooks are run before the package version is changed, so the
+// BEKU_NEW_VERSION is the version that will be checked out.
+//
+// If one of pre hook failed, it will stop and return an error ErrHook, so
+// the caller can abort the operation on package.
+// The failed post hook is reported and the next hooks are still run.
+func (env *Env) runHooks(when, op string, pkg *Package, oldVersion, newVersion string) (err error) {
+	for _, h := range env.hooks {
+		if h.when != when || !h.isMatch(op, pkg.ImportPath) {
+			continue
+		}
+
+		env.log.Printf("[ENV] %s %s >>> Running %s hook %s\n", op,
+			pkg.ImportPath, when, h.name)
+
+		cmd := exec.CommandContext(env.context(), "sh", "-c", h.exec)
+		cmd.Dir = env.prefix
+		cmd.Env = append(os.Environ(),
+			"BEKU_HOOK="+h.name,
+			"BEKU_OPERATION="+op,
+			"BEKU_PACKAGE="+pkg.ImportPath,
+			"BEKU_PACKAGE_DIR="+pkg.FullPath,
+			"BEKU_OLD_VERSION="+oldVersion,
+			"BEKU_NEW_VERSION="+newVersion,
+		)
+		cmd.Stdout = env.log.Progress()
+		cmd.Stderr = env.log.Err()
+
+		err = cmd.Run()
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("%s: %s %w: %s", pkg.ImportPath, h.name, ErrHook, err)
+		if when == hookPre {
+			return err
+		}
+
+		env.log.Warnf("[ENV] %s >>> %s\n", op, err)
+	}
+
+	return nil
+}
+
+// skipHook record the package that is skipped by operation op because its
+// pre hook failed.
+func (env *Env) skipHook(pkg *Package, op string, err error) {
+	env.log.Printf("[ENV] %s %s >>> Skipped, %s\n", op, pkg.ImportPath, err)
+
+	env.pkgsSkipped = append(env.pkgsSkipped,
+		fmt.Sprintf("%s (%s)", pkg.ImportPath, ErrHook))
+}
@@ -0,0 +1,220 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestLoadHooks(t *testing.T) {
+	cases := []struct {
+		desc     string
+		content  string
+		expErr   string
+		expHooks []*hook
+	}{{
+		desc: "With valid hook",
+		content: `[trigger]
+operation = sync
+operation = update
+target = github.com/shuLhan/*
+
+[action]
+when = post
+exec = echo $BEKU_PACKAGE
+`,
+		expHooks: []*hook{{
+			name:       "test",
+			when:       hookPost,
+			exec:       "echo $BEKU_PACKAGE",
+			operations: []string{hookOpSync, hookOpUpdate},
+			targets:    []string{"github.com/shuLhan/*"},
+		}},
+	}, {
+		desc: "With unknown operation",
+		content: `[trigger]
+operation = install
+[action]
+when = pre
+exec = true
+`,
+		expErr: `unknown operation "install"`,
+	}, {
+		desc: "With invalid when",
+		content: `[trigger]
+operation = remove
+[action]
+when = later
+exec = true
+`,
+		expErr: `invalid when "later"`,
+	}, {
+		desc: "Without exec",
+		content: `[trigger]
+operation = freeze
+[action]
+when = pre
+`,
+		expErr: `missing exec`,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		dir := t.TempDir()
+		file := filepath.Join(dir, "test"+hookExt)
+
+		testWriteFiles(t, dir, map[string]string{
+			"test" + hookExt: c.content,
+			"README":         "not a hook",
+		})
+
+		hooks, err := loadHooks(dir)
+		if err != nil {
+			test.Assert(t, "error", "loadHooks: "+file+": "+c.expErr,
+				err.Error())
+			continue
+		}
+
+		test.Assert(t, "hooks", c.expHooks, hooks)
+	}
+
+	hooks, err := loadHooks(filepath.Join(t.TempDir(), "notexist"))
+	test.Assert(t, "not exist error", nil, err)
+	test.Assert(t, "not exist hooks", 0, len(hooks))
+}
+
+func TestHookIsMatch(t *testing.T) {
+	h := &hook{
+		operations: []string{hookOpSync},
+		targets:    []string{"github.com/shuLhan/*"},
+	}
+
+	cases := []struct {
+		op         string
+		importPath string
+		exp        bool
+	}{{
+		op:         hookOpSync,
+		importPath: "github.com/shuLhan/share",
+		exp:        true,
+	}, {
+		op:         hookOpRemove,
+		importPath: "github.com/shuLhan/share",
+	}, {
+		op:         hookOpSync,
+		importPath: "github.com/other/share",
+	}, {
+		op:         hookOpSync,
+		importPath: "github.com/shuLhan/share/lib",
+	}}
+
+	for _, c := range cases {
+		test.Assert(t, c.op+" "+c.importPath, c.exp,
+			h.isMatch(c.op, c.importPath))
+	}
+}
+
+func TestEnvRunHooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	pkg := &Package{
+		ImportPath: "github.com/shuLhan/share",
+		FullPath:   "/src/github.com/shuLhan/share",
+		Version:    "v0.2.0",
+	}
+
+	env := &Env{
+		hooks: []*hook{{
+			name:       "log",
+			when:       hookPost,
+			exec:       `echo "$BEKU_HOOK $BEKU_OPERATION $BEKU_PACKAGE $BEKU_PACKAGE_DIR $BEKU_OLD_VERSION $BEKU_NEW_VERSION" > ` + out,
+			operations: []string{hookOpUpdate},
+		}, {
+			name:       "deny",
+			when:       hookPre,
+			exec:       "exit 1",
+			operations: []string{hookOpRemove},
+		}},
+	}
+
+	err := env.runHooks(hookPost, hookOpUpdate, pkg, "v0.1.0", pkg.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "post hook output",
+		"log update github.com/shuLhan/share /src/github.com/shuLhan/share v0.1.0 v0.2.0\n",
+		string(got))
+
+	err = env.runHooks(hookPre, hookOpRemove, pkg, pkg.Version, pkg.Version)
+	test.Assert(t, "errors.Is ErrHook", true, errors.Is(err, ErrHook))
+}
+
+func TestEnvRemoveWithFailedHook(t *testing.T) {
+	pkg := &Package{
+		ImportPath: "github.com/shuLhan/removed",
+		RemoteURL:  "https://github.com/shuLhan/removed",
+	}
+
+	env := &Env{
+		pkgs:      []*Package{pkg},
+		dirPkg:    t.TempDir(),
+		NoConfirm: true,
+		hooks: []*hook{{
+			name:       "deny",
+			when:       hookPre,
+			exec:       "exit 1",
+			operations: []string{hookOpRemove},
+		}},
+	}
+
+	err := env.Remove(pkg.ImportPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "pkgs", []*Package{pkg}, env.pkgs)
+}
+
+func TestEnvSyncAllWithFailedHook(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+	testTagRepo(t, pkg, "example.com/updated", true)
+
+	env := &Env{
+		pkgs:      []*Package{pkg},
+		log:       NewLogger(io.Discard, io.Discard, LogQuiet),
+		NoConfirm: true,
+		hooks: []*hook{{
+			name:       "deny",
+			when:       hookPre,
+			exec:       `test "$BEKU_NEW_VERSION" != v1.1.0`,
+			operations: []string{hookOpUpdate},
+		}},
+	}
+	env.setPackageEnv(pkg)
+
+	err := env.SyncAll()
+
+	test.Assert(t, "errors.Is ErrHook", true, errors.Is(err, ErrHook))
+	test.Assert(t, "Version", "v1.0.0", pkg.Version)
+
+	got, err := pkg.gitOutput("describe", "--tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "checked out version", "v1.0.0", got)
+}
@@ -0,0 +1,306 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// List of license identifier that is not SPDX identifier.
+const (
+	// LicenseNone define the license of package that does not have any
+	// license file.
+	LicenseNone = "none"
+
+	// LicenseUnknown define the license of package that have license
+	// file but its content does not match with any known licenses.
+	LicenseUnknown = "unknown"
+)
+
+// licenseFilePrefixes contains the prefix of license file name, in upper
+// case.
+var licenseFilePrefixes = []string{
+	"LICENSE", "LICENCE", "COPYING", "UNLICENSE",
+}
+
+// copyleftPrefixes contains the prefix of SPDX identifier of copyleft
+// licenses.
+var copyleftPrefixes = []string{
+	"AGPL-", "EPL-", "GPL-", "LGPL-", "MPL-",
+}
+
+// licenseMatcher define the SPDX license identifier and the phrases, taken
+// from the SPDX license text, that must exist in the license file.
+// The phrases are in normalized form, see normalizeLicense.
+type licenseMatcher struct {
+	id      string
+	phrases []string
+}
+
+// licenseMatchers contains list of known licenses.
+// The order is matter, license that contains the text of other license
+// (for example, LGPL contains "GNU General Public License", and MPL and EPL
+// refer to the GNU licenses as secondary licenses) must be placed before
+// it.
+var licenseMatchers = []licenseMatcher{{
+	id:      "MPL-2.0",
+	phrases: []string{"mozilla public license version 2 0"},
+}, {
+	id:      "EPL-2.0",
+	phrases: []string{"eclipse public license v 2 0"},
+}, {
+	id:      "AGPL-3.0",
+	phrases: []string{"gnu affero general public license", "version 3"},
+}, {
+	id:      "LGPL-3.0",
+	phrases: []string{"gnu lesser general public license", "version 3"},
+}, {
+	id:      "LGPL-2.1",
+	phrases: []string{"gnu lesser general public license", "version 2 1"},
+}, {
+	id:      "LGPL-2.0",
+	phrases: []string{"gnu library general public license", "version 2"},
+}, {
+	id:      "GPL-3.0",
+	phrases: []string{"gnu general public license", "version 3"},
+}, {
+	id:      "GPL-2.0",
+	phrases: []string{"gnu general public license", "version 2"},
+}, {
+	id:      "Apache-2.0",
+	phrases: []string{"apache license", "version 2 0"},
+}, {
+	id:      "BSL-1.0",
+	phrases: []string{"boost software license version 1 0"},
+}, {
+	id: "BSD-3-Clause",
+	phrases: []string{
+		"redistribution and use in source and binary forms",
+		"neither the name of",
+	},
+}, {
+	id: "BSD-2-Clause",
+	phrases: []string{
+		"redistribution and use in source and binary forms",
+	},
+}, {
+	id: "MIT",
+	phrases: []string{
+		"permission is hereby granted free of charge to any person obtaining a copy",
+	},
+}, {
+	id: "ISC",
+	phrases: []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+	},
+}, {
+	id: "Unlicense",
+	phrases: []string{
+		"this is free and unencumbered software released into the public domain",
+	},
+}, {
+	id:      "CC0-1.0",
+	phrases: []string{"creative commons legal code", "cc0 1 0"},
+}, {
+	id: "Zlib",
+	phrases: []string{
+		"altered source versions must be plainly marked as such",
+		"this notice may not be removed or altered from any source distribution",
+	},
+}}
+
+// normalizeLicense convert the license text into lower case, replace all
+// non alphanumeric characters with space, and collapse the spaces.
+func normalizeLicense(text []byte) string {
+	var (
+		sb    strings.Builder
+		space = true
+	)
+
+	for _, c := range strings.ToLower(string(text)) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+			space = false
+			continue
+		}
+		if !space {
+			sb.WriteByte(' ')
+			space = true
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// classifyLicense return the SPDX identifier of license text, or
+// LicenseUnknown if the text does not match with any known licenses.
+func classifyLicense(text []byte) string {
+	norm := normalizeLicense(text)
+
+	for _, m := range licenseMatchers {
+		found := true
+		for _, phrase := range m.phrases {
+			if !strings.Contains(norm, phrase) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return m.id
+		}
+	}
+
+	return LicenseUnknown
+}
+
+// isCopyleft will return true if the license identifier is copyleft
+// license.
+func isCopyleft(id string) bool {
+	for _, prefix := range copyleftPrefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLicenseFile will return true if the file name is license file.
+func isLicenseFile(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range licenseFilePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectLicenses classify all license files in root of package source
+// directory and return the list of unique SPDX identifiers.
+// If no license file found, it will return LicenseNone.
+func (pkg *Package) detectLicenses() (licenses []string, err error) {
+	entries, err := os.ReadDir(pkg.FullPath)
+	if err != nil {
+		return nil, fmt.Errorf("detectLicenses: %w", err)
+	}
+
+	found := make(map[string]bool)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isLicenseFile(entry.Name()) {
+			continue
+		}
+
+		text, err := os.ReadFile(filepath.Join(pkg.FullPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("detectLicenses: %w", err)
+		}
+
+		id := classifyLicense(text)
+		if !found[id] {
+			found[id] = true
+			licenses = append(licenses, id)
+		}
+	}
+
+	if len(licenses) == 0 {
+		return []string{LicenseNone}, nil
+	}
+
+	sort.Strings(licenses)
+
+	return licenses, nil
+}
+
+// QueryLicense detect the license of each package source, store it in
+// database, and print it.
+// If pkgs is not empty, only those packages will be queried.
+//
+// Package with unknown, none, or copyleft license is listed at the end.
+// If the allowed licenses is defined in "[beku]" section of database, using
+// key "license-allow", it will return ErrLicense if at least one package
+// have license that is not allowed.
+func (env *Env) QueryLicense(pkgs []string) (err error) {
+	var (
+		format     = fmt.Sprintf("%%-%ds  %%s\n", env.fmtMaxPath)
+		notices    []string
+		violations []string
+	)
+
+	for _, pkg := range env.pkgs {
+		if env.IsExcluded(pkg.ImportPath) {
+			continue
+		}
+
+		found := len(pkgs) == 0
+		for x := 0; x < len(pkgs) && !found; x++ {
+			found = pkgs[x] == pkg.ImportPath
+		}
+		if !found {
+			continue
+		}
+
+		licenses, err := pkg.detectLicenses()
+		if err != nil {
+			return fmt.Errorf("QueryLicense: %s: %w", pkg.ImportPath, err)
+		}
+
+		if strings.Join(licenses, " ") != strings.Join(pkg.Licenses, " ") {
+			pkg.Licenses = licenses
+			env.dirty = true
+		}
+
+		fmt.Fprintf(env.log.Out(), format, pkg.ImportPath,
+			strings.Join(licenses, ", "))
+
+		for _, id := range licenses {
+			switch {
+			case id == LicenseNone || id == LicenseUnknown:
+				notices = append(notices, fmt.Sprintf(format,
+					pkg.ImportPath, id))
+			case isCopyleft(id):
+				notices = append(notices, fmt.Sprintf(format,
+					pkg.ImportPath, "copyleft "+id))
+			}
+
+			if len(env.licenseAllow) > 0 && !env.isLicenseAllowed(id) {
+				violations = append(violations, fmt.Sprintf(format,
+					pkg.ImportPath, id))
+			}
+		}
+	}
+
+	if len(notices) > 0 {
+		fmt.Fprintln(env.log.Out(), "\n[ENV] QueryLicense >>> The following packages have unknown or copyleft license,")
+		for _, notice := range notices {
+			fmt.Fprint(env.log.Out(), notice)
+		}
+	}
+
+	if len(violations) > 0 {
+		fmt.Fprintln(env.log.Out(), "\n[ENV] QueryLicense >>> The following packages have license that is not allowed,")
+		for _, violation := range violations {
+			fmt.Fprint(env.log.Out(), violation)
+		}
+		return fmt.Errorf("QueryLicense: %d %w", len(violations), ErrLicense)
+	}
+
+	return nil
+}
+
+// isLicenseAllowed will return true if the license identifier is in the
+// list of allowed licenses.
+func (env *Env) isLicenseAllowed(id string) bool {
+	for _, allow := range env.licenseAllow {
+		if strings.EqualFold(allow, id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+const testLicenseMIT = `MIT License
+
+Copyright (c) 2018 Shulhan
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.
+`
+
+const testLicenseLGPL3 = `                   GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+  This version of the GNU Lesser General Public License incorporates
+the terms and conditions of version 3 of the GNU General Public
+License, supplemented by the additional permissions listed below.
+`
+
+func TestClassifyLicense(t *testing.T) {
+	cases := []struct {
+		desc string
+		text string
+		exp  string
+	}{{
+		desc: "MIT",
+		text: testLicenseMIT,
+		exp:  "MIT",
+	}, {
+		desc: "LGPL-3.0 contains GPL text",
+		text: testLicenseLGPL3,
+		exp:  "LGPL-3.0",
+	}, {
+		desc: "MPL-2.0 refer to GNU licenses",
+		text: `Mozilla Public License Version 2.0
+==================================
+
+1.12. "Secondary License"
+    means either the GNU General Public License, Version 2.0, the GNU
+    Lesser General Public License, Version 2.1, the GNU Affero General
+    Public License, Version 3.0, or any later versions of those
+    licenses.`,
+		exp: "MPL-2.0",
+	}, {
+		desc: "EPL-2.0 refer to GNU license",
+		text: `Eclipse Public License - v 2.0
+
+"Secondary License" means either the GNU General Public License,
+Version 2.0, or any later versions of that license.`,
+		exp: "EPL-2.0",
+	}, {
+		desc: "BSD-3-Clause",
+		text: `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+   * Neither the name of Google Inc. nor the names of its contributors may be
+used to endorse or promote products derived from this software.`,
+		exp: "BSD-3-Clause",
+	}, {
+		desc: "Apache-2.0",
+		text: `                                 Apache License
+                           Version 2.0, January 2004`,
+		exp: "Apache-2.0",
+	}, {
+		desc: "Unknown",
+		text: "All rights reserved.",
+		exp:  LicenseUnknown,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		test.Assert(t, "classifyLicense", c.exp,
+			classifyLicense([]byte(c.text)))
+	}
+}
+
+func TestEnvQueryLicense(t *testing.T) {
+	dir := t.TempDir()
+
+	mit := &Package{
+		ImportPath: "github.com/shuLhan/mit",
+		FullPath:   filepath.Join(dir, "mit"),
+	}
+	lgpl := &Package{
+		ImportPath: "github.com/shuLhan/lgpl",
+		FullPath:   filepath.Join(dir, "lgpl"),
+	}
+	none := &Package{
+		ImportPath: "github.com/shuLhan/none",
+		FullPath:   filepath.Join(dir, "none"),
+	}
+
+	testWriteFiles(t, mit.FullPath, map[string]string{
+		"LICENSE": testLicenseMIT,
+	})
+	testWriteFiles(t, lgpl.FullPath, map[string]string{
+		"COPYING.LESSER": testLicenseLGPL3,
+		"main.go":        "package lgpl\n",
+	})
+	err := os.MkdirAll(none.FullPath, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &Env{
+		pkgs: []*Package{mit, lgpl, none},
+	}
+
+	err = env.QueryLicense(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "mit.Licenses", []string{"MIT"}, mit.Licenses)
+	test.Assert(t, "lgpl.Licenses", []string{"LGPL-3.0"}, lgpl.Licenses)
+	test.Assert(t, "none.Licenses", []string{LicenseNone}, none.Licenses)
+	test.Assert(t, "env.dirty", true, env.dirty)
+
+	env.licenseAllow = []string{"MIT", "lgpl-3.0"}
+
+	err = env.QueryLicense([]string{mit.ImportPath, lgpl.ImportPath})
+	test.Assert(t, "QueryLicense with allowed licenses", nil, err)
+
+	err = env.QueryLicense(nil)
+	test.Assert(t, "QueryLicense with not allowed license", true,
+		errors.Is(err, ErrLicense))
+}
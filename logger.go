@@ -0,0 +1,164 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/shuLhan/share/lib/debug"
+)
+
+// LogLevel define the level of messages that is printed by Logger.
+type LogLevel int
+
+// List of log levels.
+const (
+	// LogQuiet print only the errors and the result of query operations.
+	LogQuiet LogLevel = iota
+
+	// LogNormal print the progress of operations.
+	LogNormal
+
+	// LogVerbose print the commands that are executed.
+	LogVerbose
+
+	// LogDebug print the internal states of packages.
+	LogDebug
+)
+
+// Logger write the messages of environment and packages into output and
+// error writers, filtered by level.
+//
+// The nil Logger is valid, it will write to standard output and error, with
+// level based on the DEBUG environment variable.
+type Logger struct {
+	out   io.Writer
+	err   io.Writer
+	level LogLevel
+}
+
+// NewLogger create new logger that write the messages into out and the
+// errors into errOut.
+// If out or errOut is nil, it will use the standard output or error.
+func NewLogger(out, errOut io.Writer, level LogLevel) (log *Logger) {
+	log = &Logger{
+		level: level,
+	}
+	log.SetOutput(out, errOut)
+	return log
+}
+
+// defLogLevel return the default log level based on debug.Value.
+func defLogLevel() LogLevel {
+	level := LogNormal + LogLevel(debug.Value)
+	if level > LogDebug {
+		level = LogDebug
+	}
+	return level
+}
+
+// get return the logger itself or default logger if its nil.
+func (log *Logger) get() *Logger {
+	if log != nil {
+		return log
+	}
+	return &Logger{
+		out:   defStdout,
+		err:   defStderr,
+		level: defLogLevel(),
+	}
+}
+
+// SetOutput set the writer for messages and errors.
+// If out or errOut is nil, it will use the standard output or error.
+func (log *Logger) SetOutput(out, errOut io.Writer) {
+	if out == nil {
+		out = defStdout
+	}
+	if errOut == nil {
+		errOut = defStderr
+	}
+	log.out = out
+	log.err = errOut
+}
+
+// SetLevel set the level of messages to be printed.
+func (log *Logger) SetLevel(level LogLevel) {
+	log.level = level
+}
+
+// Level return the current log level.
+func (log *Logger) Level() LogLevel {
+	return log.get().level
+}
+
+// IsVerbose will return true if the log level is verbose or debug.
+func (log *Logger) IsVerbose() bool {
+	return log.Level() >= LogVerbose
+}
+
+// IsDebug will return true if the log level is debug.
+func (log *Logger) IsDebug() bool {
+	return log.Level() >= LogDebug
+}
+
+// Out return the writer for the result of operations, which is always
+// printed regardless of level.
+func (log *Logger) Out() io.Writer {
+	return log.get().out
+}
+
+// Progress return the writer for the output of external commands, which is
+// discarded on quiet level.
+func (log *Logger) Progress() io.Writer {
+	l := log.get()
+	if l.level < LogNormal {
+		return io.Discard
+	}
+	return l.out
+}
+
+// Err return the writer for errors.
+func (log *Logger) Err() io.Writer {
+	return log.get().err
+}
+
+// Printf print the progress message, only if level is normal or higher.
+func (log *Logger) Printf(format string, args ...interface{}) {
+	l := log.get()
+	if l.level >= LogNormal {
+		fmt.Fprintf(l.out, format, args...)
+	}
+}
+
+// Println print the progress message, only if level is normal or higher.
+func (log *Logger) Println(args ...interface{}) {
+	l := log.get()
+	if l.level >= LogNormal {
+		fmt.Fprintln(l.out, args...)
+	}
+}
+
+// Verbosef print the message only if level is verbose or higher.
+func (log *Logger) Verbosef(format string, args ...interface{}) {
+	l := log.get()
+	if l.level >= LogVerbose {
+		fmt.Fprintf(l.out, format, args...)
+	}
+}
+
+// Debugf print the message only if level is debug.
+func (log *Logger) Debugf(format string, args ...interface{}) {
+	l := log.get()
+	if l.level >= LogDebug {
+		fmt.Fprintf(l.out, format, args...)
+	}
+}
+
+// Warnf print the message to error writer, regardless of level.
+func (log *Logger) Warnf(format string, args ...interface{}) {
+	fmt.Fprintf(log.get().err, format, args...)
+}
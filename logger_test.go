@@ -0,0 +1,83 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestLogger(t *testing.T) {
+	cases := []struct {
+		desc   string
+		expOut string
+		expErr string
+		level  LogLevel
+	}{{
+		desc:   "With quiet level",
+		level:  LogQuiet,
+		expOut: "result\n",
+		expErr: "warn\n",
+	}, {
+		desc:   "With normal level",
+		level:  LogNormal,
+		expOut: "result\nnormal\n",
+		expErr: "warn\n",
+	}, {
+		desc:   "With verbose level",
+		level:  LogVerbose,
+		expOut: "result\nnormal\nverbose\n",
+		expErr: "warn\n",
+	}, {
+		desc:   "With debug level",
+		level:  LogDebug,
+		expOut: "result\nnormal\nverbose\ndebug\n",
+		expErr: "warn\n",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		var out, errOut bytes.Buffer
+
+		log := NewLogger(&out, &errOut, c.level)
+
+		_, _ = log.Out().Write([]byte("result\n"))
+		log.Println("normal")
+		log.Verbosef("%s\n", "verbose")
+		log.Debugf("%s\n", "debug")
+		log.Warnf("%s\n", "warn")
+
+		test.Assert(t, "out", c.expOut, out.String())
+		test.Assert(t, "err", c.expErr, errOut.String())
+	}
+}
+
+func TestEnvSetOutput(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	env := &Env{}
+	env.SetOutput(&out, &errOut)
+	env.SetLogLevel(LogQuiet)
+
+	pkg := &Package{
+		ImportPath: "github.com/shuLhan/beku",
+	}
+	env.setPackageEnv(pkg)
+
+	env.log.Printf("[ENV] %s\n", "progress")
+	pkg.log.Warnf("[PKG] %s\n", "error")
+
+	test.Assert(t, "out", "", out.String())
+	test.Assert(t, "err", "[PKG] error\n", errOut.String())
+
+	env.SetLogLevel(LogNormal)
+
+	pkg.log.Printf("[PKG] %s\n", "progress")
+
+	test.Assert(t, "out", "[PKG] progress\n", out.String())
+}
@@ -0,0 +1,193 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+const (
+	mirrorFileConfig = "config"
+	mirrorFileHEAD   = "HEAD"
+	mirrorDirObjects = "objects"
+	mirrorSuffix     = ".git"
+)
+
+// NewPackageMirror create new package using the bare repository in mirror
+// directory as the source, without resolving the import path through
+// network.
+//
+// The mirror directory is searched for bare repository named by the longest
+// prefix of package import path or package name, with or without ".git"
+// suffix.
+// For example, package "golang.org/x/text/language" will be searched in
+// "{mirrorDir}/golang.org/x/text/language.git",
+// "{mirrorDir}/golang.org/x/text/language",
+// "{mirrorDir}/golang.org/x/text.git",
+// "{mirrorDir}/golang.org/x/text", and so on.
+//
+// The package remote URL is set to the URL of remote "origin" in the
+// mirror configuration, if its exist; otherwise it will be set to the
+// canonical URL of repository path inside the mirror directory, for example
+// "https://github.com/shuLhan/share" for "{mirrorDir}/github.com/shuLhan/share",
+// so the database can be used without mirror.
+//
+// The messages of package are printed using log; if its nil, the default
+// logger is used.
+func NewPackageMirror(log *Logger, gopathSrc, mirrorDir, name, importPath string) (
+	pkg *Package, err error,
+) {
+	repoDir, err := mirrorLookup(mirrorDir, importPath)
+	if err != nil && name != importPath {
+		repoDir, err = mirrorLookup(mirrorDir, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pkg = &Package{
+		ImportPath: importPath,
+		FullPath:   filepath.Join(gopathSrc, importPath),
+		RemoteName: gitDefRemoteName,
+		RemoteURL:  mirrorRemoteURL(mirrorDir, repoDir),
+		vcsMode:    VCSModeGit,
+		state:      packageStateNew,
+		mirrorURL:  repoDir,
+		log:        log,
+	}
+
+	pkg.log.Debugf("[PKG] NewPackageMirror >>> %+v\n", pkg)
+
+	return pkg, nil
+}
+
+// mirrorLookup return the path of bare repository in mirror directory that
+// provide the import path.
+// It will return an error ErrNotMirrored if no repository found.
+func mirrorLookup(mirrorDir, importPath string) (repoDir string, err error) {
+	if len(mirrorDir) == 0 || len(importPath) == 0 {
+		return "", fmt.Errorf("%s: %w", importPath, ErrNotMirrored)
+	}
+
+	names := strings.Split(importPath, sepImport)
+
+	for x := len(names); x > 0; x-- {
+		root := filepath.Join(mirrorDir, filepath.Join(names[:x]...))
+
+		repoDir = root + mirrorSuffix
+		if isBareRepo(repoDir) {
+			return repoDir, nil
+		}
+		if isBareRepo(root) {
+			return root, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s: %w in %s", importPath, ErrNotMirrored,
+		mirrorDir)
+}
+
+// mirrorPath return the path of bare repository for import path in mirror
+// directory.
+// If the repository is not exist yet, it will return the path with ".git"
+// suffix.
+func mirrorPath(mirrorDir, importPath string) string {
+	root := filepath.Join(mirrorDir, importPath)
+	if !isBareRepo(root+mirrorSuffix) && isBareRepo(root) {
+		return root
+	}
+	return root + mirrorSuffix
+}
+
+// mirrorUpdate create a bare mirror of repository at remote URL into
+// repoDir, if its not exist; otherwise it will set the mirror remote URL and
+// fetch all references from remote.
+// The git commands are killed when the context ctx is done.
+func mirrorUpdate(ctx context.Context, log *Logger, remoteURL, repoDir string) (err error) {
+	if !isBareRepo(repoDir) {
+		err = os.MkdirAll(filepath.Dir(repoDir), 0700)
+		if err != nil {
+			return fmt.Errorf("mirrorUpdate: %w", err)
+		}
+		return mirrorGit(ctx, log, "", "clone", "--mirror", remoteURL,
+			repoDir)
+	}
+
+	err = mirrorGit(ctx, log, repoDir, "remote", "set-url",
+		gitDefRemoteName, remoteURL)
+	if err != nil {
+		return err
+	}
+
+	return mirrorGit(ctx, log, repoDir, "fetch", "--prune", "--tags",
+		"--force", gitDefRemoteName)
+}
+
+// mirrorGit run git command with arguments inside directory, bound to the
+// context ctx.
+func mirrorGit(ctx context.Context, log *Logger, dir string, args ...string) (err error) {
+	cmd := exec.CommandContext(ctx, "git", args[0])
+	if !log.IsVerbose() && args[0] != "remote" {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = log.Progress()
+	cmd.Stderr = log.Err()
+
+	log.Verbosef("= mirrorGit %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("mirrorGit %s: %w", args[0], err)
+	}
+
+	return err
+}
+
+// mirrorRemoteURL return the URL of remote "origin" in the bare repository
+// configuration.
+// If no remote defined, it will return the canonical URL of repository
+// path relative to mirror directory, without ".git" suffix.
+func mirrorRemoteURL(mirrorDir, repoDir string) string {
+	cfg, err := ini.Open(filepath.Join(repoDir, mirrorFileConfig))
+	if err == nil {
+		url, ok := cfg.Get("remote", gitDefRemoteName, "url", "")
+		if ok && len(url) > 0 {
+			return url
+		}
+	}
+
+	root, err := filepath.Rel(mirrorDir, repoDir)
+	if err != nil {
+		root = repoDir
+	}
+	root = strings.TrimSuffix(filepath.ToSlash(root), mirrorSuffix)
+
+	return "https://" + root
+}
+
+// isBareRepo will return true if directory contains "HEAD" file and
+// "objects" directory; otherwise it will return false.
+func isBareRepo(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, mirrorFileHEAD))
+	if err != nil || fi.IsDir() {
+		return false
+	}
+
+	fi, err = os.Stat(filepath.Join(dir, mirrorDirObjects))
+	if err != nil || !fi.IsDir() {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,175 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func testCreateBareRepo(t *testing.T, dir, remoteURL string) {
+	err := os.MkdirAll(filepath.Join(dir, mirrorDirObjects), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, mirrorFileHEAD),
+		[]byte("ref: refs/heads/master\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remoteURL) == 0 {
+		return
+	}
+
+	cfg := "[remote \"origin\"]\n\turl = " + remoteURL + "\n"
+
+	err = os.WriteFile(filepath.Join(dir, mirrorFileConfig), []byte(cfg), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirrorLookup(t *testing.T) {
+	mirrorDir := t.TempDir()
+
+	testCreateBareRepo(t, filepath.Join(mirrorDir, "golang.org/x/text.git"), "")
+	testCreateBareRepo(t, filepath.Join(mirrorDir, "github.com/shuLhan/share"), "")
+
+	cases := []struct {
+		desc       string
+		mirrorDir  string
+		importPath string
+		exp        string
+		expErr     string
+	}{{
+		desc:       "With empty mirror directory",
+		importPath: "golang.org/x/text",
+		expErr:     "golang.org/x/text: " + ErrNotMirrored.Error(),
+	}, {
+		desc:       "With .git suffix",
+		mirrorDir:  mirrorDir,
+		importPath: "golang.org/x/text",
+		exp:        filepath.Join(mirrorDir, "golang.org/x/text.git"),
+	}, {
+		desc:       "With sub package",
+		mirrorDir:  mirrorDir,
+		importPath: "golang.org/x/text/language",
+		exp:        filepath.Join(mirrorDir, "golang.org/x/text.git"),
+	}, {
+		desc:       "Without .git suffix",
+		mirrorDir:  mirrorDir,
+		importPath: "github.com/shuLhan/share/lib/git",
+		exp:        filepath.Join(mirrorDir, "github.com/shuLhan/share"),
+	}, {
+		desc:       "With package not mirrored",
+		mirrorDir:  mirrorDir,
+		importPath: "golang.org/x/net",
+		expErr: "golang.org/x/net: " + ErrNotMirrored.Error() +
+			" in " + mirrorDir,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		got, err := mirrorLookup(c.mirrorDir, c.importPath)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "repoDir", c.exp, got)
+	}
+}
+
+func TestNewPackageMirror(t *testing.T) {
+	mirrorDir := t.TempDir()
+
+	testCreateBareRepo(t, filepath.Join(mirrorDir, "golang.org/x/text.git"),
+		"https://go.googlesource.com/text")
+	testCreateBareRepo(t, filepath.Join(mirrorDir, "github.com/shuLhan/share"), "")
+
+	cases := []struct {
+		desc       string
+		name       string
+		importPath string
+		expPkg     *Package
+		expErr     string
+	}{{
+		desc:       "With remote in mirror config",
+		name:       "golang.org/x/text",
+		importPath: "golang.org/x/text",
+		expPkg: &Package{
+			ImportPath: "golang.org/x/text",
+			FullPath:   filepath.Join(testEnv.dirSrc, "golang.org/x/text"),
+			RemoteName: gitDefRemoteName,
+			RemoteURL:  "https://go.googlesource.com/text",
+			vcsMode:    VCSModeGit,
+			mirrorURL:  filepath.Join(mirrorDir, "golang.org/x/text.git"),
+			state:      packageStateNew,
+			log:        testEnv.log,
+		},
+	}, {
+		desc:       "Without remote in mirror config",
+		name:       "github.com/shuLhan/share",
+		importPath: "github.com/shuLhan/share",
+		expPkg: &Package{
+			ImportPath: "github.com/shuLhan/share",
+			FullPath:   filepath.Join(testEnv.dirSrc, "github.com/shuLhan/share"),
+			RemoteName: gitDefRemoteName,
+			RemoteURL:  "https://github.com/shuLhan/share",
+			vcsMode:    VCSModeGit,
+			mirrorURL:  filepath.Join(mirrorDir, "github.com/shuLhan/share"),
+			state:      packageStateNew,
+			log:        testEnv.log,
+		},
+	}, {
+		desc:       "With package not mirrored",
+		name:       testPkgNotExist,
+		importPath: testPkgNotExist,
+		expErr: testPkgNotExist + ": " + ErrNotMirrored.Error() +
+			" in " + mirrorDir,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		got, err := NewPackageMirror(testEnv.log, testEnv.dirSrc,
+			mirrorDir, c.name, c.importPath)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "Package", c.expPkg, got)
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	mirrorDir := t.TempDir()
+
+	testCreateBareRepo(t, filepath.Join(mirrorDir, "github.com/shuLhan/share"), "")
+
+	cases := []struct {
+		importPath string
+		exp        string
+	}{{
+		importPath: "golang.org/x/text",
+		exp:        filepath.Join(mirrorDir, "golang.org/x/text.git"),
+	}, {
+		importPath: "github.com/shuLhan/share",
+		exp:        filepath.Join(mirrorDir, "github.com/shuLhan/share"),
+	}}
+
+	for _, c := range cases {
+		t.Log(c.importPath)
+
+		got := mirrorPath(mirrorDir, c.importPath)
+
+		test.Assert(t, "mirrorPath", c.exp, got)
+	}
+}
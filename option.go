@@ -0,0 +1,76 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"io"
+)
+
+// Option define the function to configure the environment that is created
+// by NewEnvironment.
+type Option func(env *Env)
+
+// WithPrefix set the directory where the packages are installed, the
+// GOPATH.
+// Default to GOPATH from user environment.
+func WithPrefix(dir string) Option {
+	return func(env *Env) {
+		env.prefix = dir
+	}
+}
+
+// WithGoRoot set the GOROOT directory, where the standard packages are
+// scanned.
+// Default to GOROOT from user environment.
+func WithGoRoot(dir string) Option {
+	return func(env *Env) {
+		env.goroot = dir
+	}
+}
+
+// WithPlatform set the target operating system and architecture of
+// installed packages.
+// Default to GOOS and GOARCH from user environment.
+func WithPlatform(goos, goarch string) Option {
+	return func(env *Env) {
+		env.goos = goos
+		env.goarch = goarch
+	}
+}
+
+// WithDatabase set the default database file, that is used by Load and Save
+// when no file is given.
+// Default to "{prefix}/var/beku/beku.db".
+func WithDatabase(file string) Option {
+	return func(env *Env) {
+		env.dbDefFile = file
+	}
+}
+
+// WithPath set the PATH environment variable that is used when running
+// "go" commands.
+// Default to PATH from user environment.
+func WithPath(path string) Option {
+	return func(env *Env) {
+		env.path = path
+	}
+}
+
+// WithOutput set the writer for messages and errors of environment and all
+// of its packages.
+// If out or errOut is nil, it will use the standard output or error.
+func WithOutput(out, errOut io.Writer) Option {
+	return func(env *Env) {
+		env.SetOutput(out, errOut)
+	}
+}
+
+// WithNoDeps if its true, the missing dependencies of package is not
+// installed.
+func WithNoDeps(noDeps bool) Option {
+	return func(env *Env) {
+		env.noDeps = noDeps
+	}
+}
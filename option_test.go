@@ -0,0 +1,75 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"go/build"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestNewEnvironmentWithOptions(t *testing.T) {
+	var (
+		prefix = t.TempDir()
+		dbFile = filepath.Join(t.TempDir(), "custom.db")
+		out    bytes.Buffer
+		errOut bytes.Buffer
+	)
+
+	env, err := NewEnvironment(
+		WithPrefix(prefix),
+		WithGoRoot(build.Default.GOROOT),
+		WithPlatform("linux", "arm64"),
+		WithDatabase(dbFile),
+		WithPath("/opt/bin"),
+		WithOutput(&out, &errOut),
+		WithNoDeps(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "prefix", prefix, env.prefix)
+	test.Assert(t, "dirBin", filepath.Join(prefix, dirBin), env.dirBin)
+	test.Assert(t, "dirPkg", filepath.Join(prefix, dirPkg, "linux_arm64"),
+		env.dirPkg)
+	test.Assert(t, "dirSrc", filepath.Join(prefix, dirSrc), env.dirSrc)
+	test.Assert(t, "dirGoRootSrc",
+		filepath.Join(build.Default.GOROOT, dirSrc), env.dirGoRootSrc)
+	test.Assert(t, "dbDefFile", dbFile, env.dbDefFile)
+	test.Assert(t, "path", "/opt/bin", env.path)
+	test.Assert(t, "noDeps", true, env.noDeps)
+	test.Assert(t, "goEnviron", []string{
+		"GOPATH=" + prefix,
+		"GOROOT=" + build.Default.GOROOT,
+		"GOOS=linux",
+		"GOARCH=arm64",
+	}, env.goEnviron())
+
+	env.log.Printf("message\n")
+	env.log.Warnf("error\n")
+
+	test.Assert(t, "output", "message\n", out.String())
+	test.Assert(t, "error output", "error\n", errOut.String())
+
+	env.dirty = true
+
+	err = env.Save("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "dbFile", dbFile, env.dbFile)
+
+	_, err = NewEnvironment(WithGoRoot(""))
+	test.Assert(t, "empty GOROOT", ErrGOROOT, err)
+}
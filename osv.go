@@ -0,0 +1,489 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	osvEcosystemGo = "Go"
+	osvRangeSemver = "SEMVER"
+	osvRangeEco    = "ECOSYSTEM"
+	osvExtJSON     = ".json"
+	osvExtZip      = ".zip"
+)
+
+// osvEntry define the subset of Open Source Vulnerability (OSV) record that
+// is used to match the package.
+// See https://ossf.github.io/osv-schema for the full schema.
+type osvEntry struct {
+	ID       string        `json:"id"`
+	Summary  string        `json:"summary"`
+	Aliases  []string      `json:"aliases"`
+	Affected []osvAffected `json:"affected"`
+}
+
+type osvAffected struct {
+	Package  osvPackage `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+type osvPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+// version return the version of event.
+func (ev *osvEvent) version() string {
+	switch {
+	case len(ev.Introduced) > 0:
+		return ev.Introduced
+	case len(ev.Fixed) > 0:
+		return ev.Fixed
+	}
+	return ev.LastAffected
+}
+
+// isAffected will return true if the module version is affected by the
+// vulnerability, by checking the list of affected versions and the SEMVER
+// ranges.
+func (aff *osvAffected) isAffected(version string) bool {
+	version = strings.TrimPrefix(version, "v")
+
+	for _, v := range aff.Versions {
+		if strings.TrimPrefix(v, "v") == version {
+			return true
+		}
+	}
+
+	for _, r := range aff.Ranges {
+		if r.Type != osvRangeSemver && r.Type != osvRangeEco {
+			continue
+		}
+
+		events := make([]osvEvent, len(r.Events))
+		copy(events, r.Events)
+		sort.SliceStable(events, func(x, y int) bool {
+			return compareSemver(events[x].version(), events[y].version()) < 0
+		})
+
+		var affected bool
+		for _, ev := range events {
+			switch {
+			case len(ev.Introduced) > 0:
+				// Introduced "0" include the pseudo-version
+				// before the first tag.
+				if ev.Introduced == "0" ||
+					compareSemver(version, ev.Introduced) >= 0 {
+					affected = true
+				}
+			case len(ev.Fixed) > 0:
+				if compareSemver(version, ev.Fixed) >= 0 {
+					affected = false
+				}
+			case len(ev.LastAffected) > 0:
+				if compareSemver(version, ev.LastAffected) > 0 {
+					affected = false
+				}
+			}
+		}
+		if affected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fixedVersion return the lowest fixed version that is greater than
+// version, or empty string if no fix available.
+func (aff *osvAffected) fixedVersion(version string) (fixed string) {
+	version = strings.TrimPrefix(version, "v")
+
+	for _, r := range aff.Ranges {
+		for _, ev := range r.Events {
+			if len(ev.Fixed) == 0 {
+				continue
+			}
+			if compareSemver(ev.Fixed, version) <= 0 {
+				continue
+			}
+			if len(fixed) == 0 || compareSemver(ev.Fixed, fixed) < 0 {
+				fixed = ev.Fixed
+			}
+		}
+	}
+	if len(fixed) > 0 {
+		fixed = "v" + fixed
+	}
+	return fixed
+}
+
+// compareSemver compare two semantic versions, with or without "v" prefix.
+// It will return -1 if a is less than b, 1 if a is greater than b, or 0 if
+// both are equal.
+// The version "0" is equal to "0.0.0".
+func compareSemver(a, b string) int {
+	a = strings.TrimPrefix(a, "v")
+	b = strings.TrimPrefix(b, "v")
+
+	// Build metadata is ignored.
+	if idx := strings.IndexByte(a, '+'); idx >= 0 {
+		a = a[:idx]
+	}
+	if idx := strings.IndexByte(b, '+'); idx >= 0 {
+		b = b[:idx]
+	}
+
+	var preA, preB string
+	if idx := strings.IndexByte(a, '-'); idx >= 0 {
+		a, preA = a[:idx], a[idx+1:]
+	}
+	if idx := strings.IndexByte(b, '-'); idx >= 0 {
+		b, preB = b[:idx], b[idx+1:]
+	}
+
+	numsA := strings.Split(a, ".")
+	numsB := strings.Split(b, ".")
+	for x := 0; x < 3; x++ {
+		var na, nb int
+		if x < len(numsA) {
+			na, _ = strconv.Atoi(numsA[x])
+		}
+		if x < len(numsB) {
+			nb, _ = strconv.Atoi(numsB[x])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case len(preA) == 0:
+		return 1
+	case len(preB) == 0:
+		return -1
+	}
+
+	idsA := strings.Split(preA, ".")
+	idsB := strings.Split(preB, ".")
+	for x := 0; x < len(idsA) && x < len(idsB); x++ {
+		if idsA[x] == idsB[x] {
+			continue
+		}
+		na, errA := strconv.Atoi(idsA[x])
+		nb, errB := strconv.Atoi(idsB[x])
+		switch {
+		case errA == nil && errB == nil:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case idsA[x] < idsB[x]:
+			return -1
+		}
+		return 1
+	}
+
+	switch {
+	case len(idsA) < len(idsB):
+		return -1
+	case len(idsA) > len(idsB):
+		return 1
+	}
+	return 0
+}
+
+// loadOSV load the OSV records for Go ecosystem from directory that contains
+// JSON files, or from zip file, for example the "all.zip" from OSV database.
+func loadOSV(path string) (entries []*osvEntry, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadOSV: %w", err)
+	}
+
+	if fi.IsDir() {
+		err = filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || filepath.Ext(file) != osvExtJSON {
+				return nil
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			entries, err = appendOSVEntry(entries, file, b)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loadOSV: %w", err)
+		}
+		return entries, nil
+	}
+
+	if filepath.Ext(path) != osvExtZip {
+		return nil, fmt.Errorf("loadOSV: %s: not a directory or zip file", path)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadOSV: %w", err)
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if filepath.Ext(zf.Name) != osvExtJSON {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("loadOSV: %w", err)
+		}
+		b, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("loadOSV: %s: %w", zf.Name, err)
+		}
+
+		entries, err = appendOSVEntry(entries, zf.Name, b)
+		if err != nil {
+			return nil, fmt.Errorf("loadOSV: %w", err)
+		}
+	}
+
+	return entries, nil
+}
+
+// appendOSVEntry parse the OSV record from JSON and append it to entries
+// only if its affect Go module.
+func appendOSVEntry(entries []*osvEntry, name string, b []byte) ([]*osvEntry, error) {
+	entry := &osvEntry{}
+
+	err := json.Unmarshal(b, entry)
+	if err != nil {
+		return entries, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var affected []osvAffected
+	for _, aff := range entry.Affected {
+		if aff.Package.Ecosystem == osvEcosystemGo {
+			affected = append(affected, aff)
+		}
+	}
+	if len(affected) == 0 {
+		return entries, nil
+	}
+	entry.Affected = affected
+
+	return append(entries, entry), nil
+}
+
+// auditResult contains the vulnerability that affect the package.
+type auditResult struct {
+	pkg      *Package
+	version  string
+	id       string
+	fixed    string
+	topLevel []string
+}
+
+// Audit match the version of all packages in database with the Open Source
+// Vulnerability (OSV) records loaded from local directory or zip file in
+// osvDB, without network access.
+//
+// The package is matched by its module path in go.mod, so the record of
+// module with major version suffix or module inside the package repository
+// does not match the package.
+// The package version that is not a tag is mapped to the pseudo-version,
+// based on the nearest tag before it.
+// The package with unknown version is reported and skipped.
+// For each vulnerable package, it will print the advisory ID, the version
+// that fix the vulnerability, and the top level packages that require it.
+//
+// It will return ErrVulnerable if at least one vulnerability is found.
+func (env *Env) Audit(osvDB string) (err error) {
+	entries, err := loadOSV(osvDB)
+	if err != nil {
+		return fmt.Errorf("Audit: %w", err)
+	}
+
+	var results []*auditResult
+
+	for _, pkg := range env.pkgs {
+		if env.IsExcluded(pkg.ImportPath) {
+			continue
+		}
+
+		modPath, err := pkg.modulePath()
+		if err != nil {
+			return fmt.Errorf("Audit: %s: %w", pkg.ImportPath, err)
+		}
+
+		version, err := pkg.moduleVersion()
+		if err != nil {
+			env.log.Warnf("[ENV] Audit %s >>> Skipped, unknown version: %s\n",
+				pkg.ImportPath, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			for x := range entry.Affected {
+				aff := &entry.Affected[x]
+				if aff.Package.Name != modPath {
+					continue
+				}
+				if !aff.isAffected(version) {
+					continue
+				}
+
+				results = append(results, &auditResult{
+					pkg:      pkg,
+					version:  version,
+					id:       entry.ID,
+					fixed:    aff.fixedVersion(version),
+					topLevel: env.topLevel(pkg),
+				})
+				break
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		env.log.Printf("[ENV] Audit >>> No vulnerabilities found in %d records.\n",
+			len(entries))
+		return nil
+	}
+
+	fmt.Fprint(env.log.Out(), env.formatAuditResults(results))
+
+	return fmt.Errorf("Audit: %d %w", len(results), ErrVulnerable)
+}
+
+// formatAuditResults return the vulnerable packages as table.
+func (env *Env) formatAuditResults(results []*auditResult) string {
+	var buf strings.Builder
+
+	format := fmt.Sprintf("%%-%ds  %%-12s  %%-20s  %%-12s  %%s\n",
+		env.fmtMaxPath)
+
+	fmt.Fprintf(&buf, format, "ImportPath", "Version", "ID", "Fixed",
+		"RequiredBy")
+
+	for _, res := range results {
+		fixed := res.fixed
+		if len(fixed) == 0 {
+			fixed = "-"
+		}
+		fmt.Fprintf(&buf, format, res.pkg.ImportPath, res.version,
+			res.id, fixed, strings.Join(res.topLevel, " "))
+	}
+
+	return buf.String()
+}
+
+// topLevel return the packages that are not required by any packages, and
+// require the pkg directly or indirectly.
+// If the pkg is not required by any packages, it will return the pkg
+// itself.
+func (env *Env) topLevel(pkg *Package) (tops []string) {
+	visited := map[string]bool{pkg.ImportPath: true}
+	queue := []*Package{pkg}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		if len(cur.RequiredBy) == 0 {
+			tops = append(tops, cur.ImportPath)
+			continue
+		}
+
+		for _, reqBy := range cur.RequiredBy {
+			if visited[reqBy] {
+				continue
+			}
+			visited[reqBy] = true
+
+			_, reqPkg := env.GetPackageFromDB(reqBy, "")
+			if reqPkg == nil {
+				continue
+			}
+			queue = append(queue, reqPkg)
+		}
+	}
+
+	sort.Strings(tops)
+
+	return tops
+}
+
+// modulePath return the module path of package, from the "module"
+// directive in its go.mod file.
+// If the package does not have go.mod file, it will return the package
+// import path.
+func (pkg *Package) modulePath() (modPath string, err error) {
+	b, err := os.ReadFile(filepath.Join(pkg.FullPath, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg.ImportPath, nil
+		}
+		return "", fmt.Errorf("modulePath: %w", err)
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`"), nil
+		}
+	}
+
+	return pkg.ImportPath, nil
+}
+
+// moduleVersion return the package version as module version.
+// If package version is not a tag, it will return the pseudo-version of the
+// commit, based on the nearest tag before it.
+func (pkg *Package) moduleVersion() (version string, err error) {
+	if pkg.isTag {
+		return pkg.Version, nil
+	}
+	if pkg.vcsMode == VCSModeGit {
+		return pkg.gitPseudoVersion(pkg.Version)
+	}
+	return "", fmt.Errorf("moduleVersion: %w %s", ErrVCS, pkg.vcsMode)
+}
@@ -0,0 +1,324 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+const testOSVEntry = `{
+	"id": "GO-2018-0001",
+	"aliases": ["CVE-2018-0001"],
+	"affected": [{
+		"package": {
+			"ecosystem": "Go",
+			"name": "github.com/shuLhan/vuln"
+		},
+		"ranges": [{
+			"type": "SEMVER",
+			"events": [
+				{"introduced": "0"},
+				{"fixed": "1.2.0"},
+				{"introduced": "1.3.0"},
+				{"fixed": "1.3.2"}
+			]
+		}]
+	}]
+}`
+
+func TestCompareSemver(t *testing.T) {
+	cases := []struct {
+		a, b string
+		exp  int
+	}{
+		{"v1.0.0", "1.0.0", 0},
+		{"0", "v0.0.0", 0},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v2.0.0", "v1.10.0", 1},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-alpha", "v1.0.0-1", 1},
+		{"v1.0.0+build", "v1.0.0", 0},
+	}
+
+	for _, c := range cases {
+		test.Assert(t, c.a+" <=> "+c.b, c.exp, compareSemver(c.a, c.b))
+	}
+}
+
+func TestOSVAffected(t *testing.T) {
+	entries, err := appendOSVEntry(nil, "test.json", []byte(testOSVEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aff := &entries[0].Affected[0]
+
+	cases := []struct {
+		version  string
+		expFixed string
+		exp      bool
+	}{{
+		version:  "v1.1.9",
+		exp:      true,
+		expFixed: "v1.2.0",
+	}, {
+		version:  "v1.2.0",
+		expFixed: "v1.3.2",
+	}, {
+		version:  "v1.3.1",
+		exp:      true,
+		expFixed: "v1.3.2",
+	}, {
+		version: "v1.3.2",
+	}, {
+		version:  "v0.0.0-20180101000000-abcdefabcdef",
+		exp:      true,
+		expFixed: "v1.2.0",
+	}, {
+		version:  "v1.3.2-0.20180101000000-abcdefabcdef",
+		exp:      true,
+		expFixed: "v1.3.2",
+	}}
+
+	for _, c := range cases {
+		test.Assert(t, "isAffected "+c.version, c.exp,
+			aff.isAffected(c.version))
+		test.Assert(t, "fixedVersion "+c.version, c.expFixed,
+			aff.fixedVersion(c.version))
+	}
+}
+
+func TestLoadOSV(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "GO-2018-0001.json"),
+		[]byte(testOSVEntry), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "PYSEC-2018-0001.json"),
+		[]byte(`{"id":"PYSEC-2018-0001","affected":[{"package":{"ecosystem":"PyPI","name":"vuln"}}]}`),
+		0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := loadOSV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "len(entries) from directory", 1, len(entries))
+
+	zipFile := filepath.Join(t.TempDir(), "all.zip")
+	f, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("GO-2018-0001.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(testOSVEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = loadOSV(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "len(entries) from zip", 1, len(entries))
+	test.Assert(t, "entries[0].ID", "GO-2018-0001", entries[0].ID)
+}
+
+func TestEnvAudit(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "GO-2018-0001.json"),
+		[]byte(testOSVEntry), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vuln := &Package{
+		ImportPath: "github.com/shuLhan/vuln",
+		FullPath:   filepath.Join(dir, "vuln"),
+		RemoteURL:  "https://github.com/shuLhan/vuln",
+		Version:    "v1.3.0",
+		RequiredBy: []string{"github.com/shuLhan/lib"},
+		isTag:      true,
+	}
+	lib := &Package{
+		ImportPath: "github.com/shuLhan/lib",
+		FullPath:   filepath.Join(dir, "lib"),
+		RemoteURL:  "https://github.com/shuLhan/lib",
+		Version:    "v0.1.0",
+		Deps:       []string{"github.com/shuLhan/vuln"},
+		RequiredBy: []string{"github.com/shuLhan/app"},
+		isTag:      true,
+	}
+	app := &Package{
+		ImportPath: "github.com/shuLhan/app",
+		FullPath:   filepath.Join(dir, "app"),
+		RemoteURL:  "https://github.com/shuLhan/app",
+		Version:    "v0.1.0",
+		Deps:       []string{"github.com/shuLhan/lib"},
+		isTag:      true,
+	}
+
+	env := &Env{
+		pkgs: []*Package{vuln, lib, app},
+	}
+
+	test.Assert(t, "topLevel", []string{"github.com/shuLhan/app"},
+		env.topLevel(vuln))
+
+	err = env.Audit(dir)
+	test.Assert(t, "Audit on vulnerable package", true,
+		errors.Is(err, ErrVulnerable))
+
+	// The package with major version suffix is different module.
+	testWriteFiles(t, vuln.FullPath, map[string]string{
+		"go.mod": "module github.com/shuLhan/vuln/v2\n",
+	})
+
+	err = env.Audit(dir)
+	test.Assert(t, "Audit on package with different module", nil, err)
+
+	err = os.Remove(filepath.Join(vuln.FullPath, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vuln.Version = "v1.3.2"
+
+	err = env.Audit(dir)
+	test.Assert(t, "Audit on fixed package", nil, err)
+}
+
+func TestPackageModulePath(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		desc  string
+		gomod string
+		exp   string
+	}{{
+		desc: "Without go.mod",
+		exp:  "github.com/shuLhan/vuln",
+	}, {
+		desc:  "With major version suffix",
+		gomod: "// Comment.\nmodule github.com/shuLhan/vuln/v2 // v2\n\ngo 1.18\n",
+		exp:   "github.com/shuLhan/vuln/v2",
+	}, {
+		desc:  "With quoted module path",
+		gomod: "module \"github.com/shuLhan/vuln\"\n",
+		exp:   "github.com/shuLhan/vuln",
+	}}
+
+	for x, c := range cases {
+		t.Log(c.desc)
+
+		pkg := &Package{
+			ImportPath: "github.com/shuLhan/vuln",
+			FullPath:   filepath.Join(dir, strconv.Itoa(x)),
+		}
+		if len(c.gomod) > 0 {
+			testWriteFiles(t, pkg.FullPath, map[string]string{
+				"go.mod": c.gomod,
+			})
+		}
+
+		got, err := pkg.modulePath()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, "modulePath", c.exp, got)
+	}
+}
+
+func TestPackageModuleVersion(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+
+	cases := []struct {
+		desc string
+		date string
+		git  [][]string
+		exp  string
+	}{{
+		desc: "Without tag",
+		date: "2018-01-01T00:00:00Z",
+		exp:  "v0.0.0-20180101000000-",
+	}, {
+		desc: "With tagged commit",
+		date: "2018-01-01T00:00:00Z",
+		git:  [][]string{{"tag", "v1.2.3"}},
+		exp:  "v1.2.3",
+	}, {
+		desc: "After release tag",
+		date: "2018-01-02T00:00:00Z",
+		git: [][]string{
+			{"commit", "--quiet", "--allow-empty", "-m", "second"},
+		},
+		exp: "v1.2.4-0.20180102000000-",
+	}, {
+		desc: "After pre-release tag",
+		date: "2018-01-03T00:00:00Z",
+		git: [][]string{
+			{"tag", "v2.0.0-rc.1"},
+			{"commit", "--quiet", "--allow-empty", "-m", "third"},
+		},
+		exp: "v2.0.0-rc.1.0.20180103000000-",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		for _, args := range c.git {
+			testGit(t, pkg.FullPath, c.date, args...)
+		}
+
+		commit, err := pkg.gitRevParse("HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(c.exp, "-") {
+			c.exp += commit[:12]
+		}
+
+		pkg.Version = commit
+
+		got, err := pkg.moduleVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, "moduleVersion", c.exp, got)
+	}
+
+	pkg.Version = "0123456789abcdef"
+
+	_, err := pkg.moduleVersion()
+	test.Assert(t, "unknown version is error", true, err != nil)
+}
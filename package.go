@@ -6,6 +6,7 @@ package beku
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go/build"
 	"os"
@@ -16,7 +17,6 @@ import (
 
 	"golang.org/x/tools/go/vcs"
 
-	"github.com/shuLhan/share/lib/debug"
 	"github.com/shuLhan/share/lib/git"
 	"github.com/shuLhan/share/lib/ini"
 	libio "github.com/shuLhan/share/lib/io"
@@ -28,33 +28,116 @@ const (
 
 // Package define Go package information: path to package, version, whether is
 // tag or not, and VCS mode.
+//
+// If the package is a fork, the remote URL is the fork repository and the
+// UpstreamURL is the original repository where the package forked from.
+// UpstreamTags contains the tags in upstream repository that has not been
+// merged into the fork, as of the last FetchLatestVersion.
+//
+// CommitHash and TreeHash are the commit and tree that the package Version
+// resolved to, at the time the package is synced.
+// They are used to detect tag that has been moved.
+//
+// Signature define the policy to verify the package version signature,
+// its override the global policy.
+//
+// Licenses contains the SPDX identifiers of license files in package
+// source, as of the last QueryLicense.
+//
+// All git and go commands that are run on package are bound to the
+// package context, which is set by the environment during operation with
+// context, for example SyncAllContext.
 type Package struct {
 	ImportPath   string
 	FullPath     string
 	RemoteName   string
 	RemoteURL    string
 	RemoteBranch string
+	UpstreamURL  string
 	Version      string
 	VersionNext  string
+	CommitHash   string
+	TreeHash     string
+	Signature    string
+	UpstreamTags []string
+	Licenses     []string
 	DepsMissing  []string
 	Deps         []string
 	RequiredBy   []string
 	vcsMode      string
+	mirrorURL    string
+	urlRewrites  urlRewrites
+	signature    *signaturePolicy
+	stashed      *dirtyStatus
+	log          *Logger
+	goEnv        []string
+	ctx          context.Context
 	state        packageState
 	isTag        bool
 }
 
 // NewPackage create a package set the package version, tag status, and
 // dependencies.
-func NewPackage(gopathSrc, name, importPath string) (pkg *Package, err error) {
-	repoRoot, err := vcs.RepoRootForImportPath(name, debug.Value >= 1)
+// The messages of package, including when resolving the import path, are
+// printed using log; if its nil, the default logger is used.
+func NewPackage(log *Logger, gopathSrc, name, importPath string) (pkg *Package, err error) {
+	repoRoot, err := vcs.RepoRootForImportPath(name, log.IsVerbose())
 	if err != nil {
-		fmt.Fprintf(defStderr, "[PKG] NewPackage >>> error: %s\n", err.Error())
+		log.Warnf("[PKG] NewPackage >>> error: %s\n", err.Error())
 		return
 	}
 
+	return newPackageFromRepoRoot(log, gopathSrc, importPath, repoRoot)
+}
+
+// NewPackageLocal create a package from repository that already exist in
+// "src" directory.
+// The VCS mode, remote name, and remote URL are read from the local
+// repository metadata, without any network access.
+// The remote is the remote of current branch, the remote "origin", or the
+// first remote in repository, in that order.
+// The messages of package are printed using log; if its nil, the default
+// logger is used.
+func NewPackageLocal(log *Logger, gopathSrc, importPath string) (pkg *Package, err error) {
+	fullPath := filepath.Join(gopathSrc, importPath)
+
+	vcsCmd, root, err := vcs.FromDir(fullPath, gopathSrc)
+	if err != nil {
+		return nil, fmt.Errorf("NewPackageLocal: %w", err)
+	}
+
+	repoRoot := &vcs.RepoRoot{
+		VCS:  vcsCmd,
+		Root: root,
+	}
+
+	var remoteName string
+	if vcsCmd.Cmd == VCSModeGit {
+		remoteName = gitLocalRemoteName(fullPath)
+		repoRoot.Repo, err = git.GetRemoteURL(fullPath, remoteName)
+		if err != nil {
+			return nil, fmt.Errorf("NewPackageLocal: %w", err)
+		}
+	}
+
+	pkg, err = newPackageFromRepoRoot(log, gopathSrc, importPath, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(remoteName) > 0 {
+		pkg.RemoteName = remoteName
+	}
+
+	return pkg, nil
+}
+
+// newPackageFromRepoRoot create new package using the VCS and repository
+// URL from repoRoot, and print the package messages using log.
+func newPackageFromRepoRoot(log *Logger, gopathSrc, importPath string, repoRoot *vcs.RepoRoot) (
+	pkg *Package, err error,
+) {
 	if repoRoot.VCS.Cmd != VCSModeGit {
-		err = fmt.Errorf(errVCS, repoRoot.VCS.Cmd)
+		err = fmt.Errorf("%w %s", ErrVCS, repoRoot.VCS.Cmd)
 		return nil, err
 	}
 
@@ -65,12 +148,20 @@ func NewPackage(gopathSrc, name, importPath string) (pkg *Package, err error) {
 		RemoteURL:  repoRoot.Repo,
 		vcsMode:    repoRoot.VCS.Cmd,
 		state:      packageStateNew,
+		log:        log,
 	}
 
-	if debug.Value >= 2 {
-		fmt.Printf("[PKG] NewPackage >>> %+v\n", pkg)
-	}
+	pkg.log.Debugf("[PKG] NewPackage >>> %+v\n", pkg)
+
+	return pkg, nil
+}
 
+// Bundle create a single file that contains the package repository at
+// current version, which can be cloned or fetched without network.
+func (pkg *Package) Bundle(file string) (err error) {
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitBundle(file)
+	}
 	return
 }
 
@@ -83,7 +174,7 @@ func (pkg *Package) CheckoutVersion(newVersion string) (err error) {
 				return
 			}
 		}
-		err = git.CheckoutRevision(pkg.FullPath, pkg.RemoteName, pkg.RemoteBranch, newVersion)
+		err = pkg.gitCheckoutRevision(newVersion)
 	}
 	return
 }
@@ -91,7 +182,7 @@ func (pkg *Package) CheckoutVersion(newVersion string) (err error) {
 // CompareVersion will compare package version using current package as base.
 func (pkg *Package) CompareVersion(newPkg *Package) (err error) {
 	if pkg.vcsMode == VCSModeGit {
-		err = git.LogRevisions(pkg.FullPath, pkg.Version, newPkg.Version)
+		err = pkg.gitLogRevisions(pkg.Version, newPkg.Version)
 	}
 
 	return
@@ -99,22 +190,37 @@ func (pkg *Package) CompareVersion(newPkg *Package) (err error) {
 
 // FetchLatestVersion will try to update the package and get the latest
 // version (tag or commit).
+//
+// If the package have upstream, it will also set the UpstreamTags from the
+// upstream repository, without changing the package repository, except
+// when the package is fetched from mirror.
 func (pkg *Package) FetchLatestVersion() (err error) {
 	if pkg.vcsMode == VCSModeGit {
-		err = git.FetchAll(pkg.FullPath)
+		err = pkg.gitFetch()
 		if err != nil {
 			return
 		}
 		if pkg.isTag {
-			pkg.VersionNext, err = git.LatestTag(pkg.FullPath)
+			pkg.VersionNext, err = pkg.gitLatestTag()
 		} else {
-			pkg.VersionNext, err = git.LatestCommit(pkg.FullPath, "")
+			pkg.VersionNext, err = pkg.gitLatestCommit()
 		}
+		if err != nil {
+			return
+		}
+		if len(pkg.UpstreamURL) == 0 || len(pkg.mirrorURL) > 0 {
+			return
+		}
+		pkg.UpstreamTags, err = pkg.gitUpstreamTags()
 	}
 
 	return
 }
 
+// Freeze set the package remote, branch, and working tree to the package
+// version.
+// The version is verified before the package is changed, if the package
+// require signature.
 func (pkg *Package) Freeze() (err error) {
 	if pkg.vcsMode == VCSModeGit {
 		err = pkg.gitFreeze()
@@ -131,19 +237,19 @@ func (pkg *Package) GoClean() (err error) {
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return fmt.Errorf(`%s: %s`, logp, err)
+		return fmt.Errorf(`%s: %w`, logp, err)
 	}
 
-	cmd := exec.Command("go", "clean", "-i", "./...")
+	cmd := exec.CommandContext(pkg.context(), "go", "clean", "-i", "./...")
 	cmd.Dir = pkg.FullPath
 	cmd.Env = append(cmd.Env, "GO111MODULE=off")
-	cmd.Env = append(cmd.Env, "GOPATH="+build.Default.GOPATH)
-	cmd.Stdout = defStdout
-	cmd.Stderr = defStderr
+	cmd.Env = append(cmd.Env, pkg.goEnviron()...)
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
 
 	err = cmd.Run()
 	if err != nil {
-		return fmt.Errorf(`%s: %s`, logp, err)
+		return fmt.Errorf(`%s: %w`, logp, err)
 	}
 
 	return nil
@@ -155,7 +261,28 @@ func (pkg *Package) Install() (err error) {
 	var logp = `Install`
 
 	if pkg.vcsMode == VCSModeGit {
-		err = pkg.gitInstall()
+		err = pkg.gitInstall(pkg.mirrorURL)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+	return nil
+}
+
+// InstallFromMirror install a package by cloning the bare repository in
+// mirror directory, and then set the package remote back to its remote URL.
+// Unlike Install on package with mirror, future fetch on this package will
+// use the remote URL.
+func (pkg *Package) InstallFromMirror(mirrorDir string) (err error) {
+	var logp = `InstallFromMirror`
+
+	repoDir, err := mirrorLookup(mirrorDir, pkg.ImportPath)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitInstall(repoDir)
 		if err != nil {
 			return fmt.Errorf(`%s: %w`, logp, err)
 		}
@@ -203,14 +330,15 @@ func (pkg *Package) IsNewer(older *Package) bool {
 func (pkg *Package) Remove() (err error) {
 	var logp = `Remove`
 
+	// The source is still removed if the package context is done, so
+	// the package that is partially installed can be cleaned up after
+	// the operation is canceled.
 	err = pkg.GoClean()
-	if err != nil {
+	if err != nil && pkg.context().Err() == nil {
 		return fmt.Errorf(`%s: %w`, logp, err)
 	}
 
-	if debug.Value >= 1 {
-		fmt.Printf("%s: %s >>> %s\n", logp, pkg.ImportPath, pkg.FullPath)
-	}
+	pkg.log.Verbosef("%s: %s >>> %s\n", logp, pkg.ImportPath, pkg.FullPath)
 
 	err = os.RemoveAll(pkg.FullPath)
 	if err != nil {
@@ -260,8 +388,8 @@ func (pkg *Package) Scan() (err error) {
 // ScanDeps will scan package dependencies, removing standard packages, keep
 // only external dependencies.
 func (pkg *Package) ScanDeps(env *Env) (err error) {
-	if debug.Value >= 1 {
-		fmt.Println("[PKG] ScanDeps", pkg.ImportPath)
+	if pkg.log.IsVerbose() {
+		pkg.log.Println("[PKG] ScanDeps", pkg.ImportPath)
 	}
 
 	imports, err := pkg.GetRecursiveImports(env)
@@ -281,17 +409,23 @@ func (pkg *Package) ScanDeps(env *Env) (err error) {
 func (pkg *Package) GetRecursiveImports(env *Env) (
 	imports []string, err error,
 ) {
-	cmd := exec.Command("go", "list", "-e", "-f", `{{ join .Imports "\n"}}`, "./...")
+	return pkg.GetRecursiveImportsContext(pkg.context(), env)
+}
+
+// GetRecursiveImportsContext is like GetRecursiveImports but the `go list`
+// command is killed when the context ctx is done.
+func (pkg *Package) GetRecursiveImportsContext(ctx context.Context, env *Env) (
+	imports []string, err error,
+) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-f", `{{ join .Imports "\n"}}`, "./...")
 	cmd.Dir = pkg.FullPath
-	cmd.Stderr = defStderr
+	cmd.Stderr = pkg.log.Err()
 
-	if debug.Value >= 1 {
-		fmt.Printf("= GetRecursiveImports %s %s\n", cmd.Dir, cmd.Args)
-	}
+	pkg.log.Verbosef("= GetRecursiveImports %s %s\n", cmd.Dir, cmd.Args)
 
 	out, err := cmd.Output()
 	if err != nil {
-		err = fmt.Errorf("GetRecursiveImports: %s", err)
+		err = fmt.Errorf("GetRecursiveImports: %w", err)
 		return
 	}
 
@@ -341,10 +475,8 @@ func (pkg *Package) addDep(env *Env, importPath string) bool {
 
 	// (1)
 	if strings.HasPrefix(importPath, pkg.ImportPath) {
-		if debug.Value >= 2 {
-			fmt.Printf("[PKG] addDep %s >>> skip self import: %s\n",
-				pkg.ImportPath, importPath)
-		}
+		pkg.log.Debugf("[PKG] addDep %s >>> skip self import: %s\n",
+			pkg.ImportPath, importPath)
 		return false
 	}
 
@@ -364,10 +496,8 @@ func (pkg *Package) addDep(env *Env, importPath string) bool {
 		if pkgs[0] != env.pkgsStd[x] {
 			continue
 		}
-		if debug.Value >= 2 {
-			fmt.Printf("[PKG] addDep %s >>> skip std: %s\n",
-				pkg.ImportPath, importPath)
-		}
+		pkg.log.Debugf("[PKG] addDep %s >>> skip std: %s\n",
+			pkg.ImportPath, importPath)
 		return false
 	}
 
@@ -383,10 +513,8 @@ func (pkg *Package) addDep(env *Env, importPath string) bool {
 		return true
 	}
 
-	if debug.Value >= 2 {
-		fmt.Printf("[PKG] addDep %s >>> missing: %s\n",
-			pkg.ImportPath, importPath)
-	}
+	pkg.log.Debugf("[PKG] addDep %s >>> missing: %s\n",
+		pkg.ImportPath, importPath)
 
 	// (5.2)
 	pkg.pushMissing(importPath)
@@ -402,7 +530,11 @@ func (pkg *Package) load(sec *ini.Section) {
 	pkg.RemoteName = sec.Val(keyRemoteName)
 	pkg.RemoteURL = sec.Val(keyRemoteURL)
 	pkg.RemoteBranch = sec.Val(keyRemoteBranch)
+	pkg.UpstreamURL = sec.Val(keyUpstreamURL)
 	pkg.Version = sec.Val(keyVersion)
+	pkg.CommitHash = sec.Val(keyCommit)
+	pkg.TreeHash = sec.Val(keyTree)
+	pkg.Signature = sec.Val(keySignature)
 	pkg.isTag = IsTagVersion(pkg.Version)
 
 	vals := sec.Vals(keyDeps)
@@ -419,6 +551,8 @@ func (pkg *Package) load(sec *ini.Section) {
 	for x := 0; x < len(vals); x++ {
 		pkg.pushRequiredBy(vals[x])
 	}
+
+	pkg.Licenses = sec.Vals(keyLicense)
 }
 
 // GoInstall a package recursively ("./...").
@@ -426,8 +560,52 @@ func (pkg *Package) load(sec *ini.Section) {
 // Set PATH to let go install that require gcc work when invoked from
 // non-interactive shell (e.g. buildbot).
 func (pkg *Package) GoInstall(envPath string) (err error) {
-	cmd := exec.Command("go", "install")
-	if debug.Value >= 2 {
+	cmd := pkg.goCommand(envPath, "install")
+
+	if !pkg.log.IsVerbose() {
+		pkg.log.Printf("= GoInstall %s\n", cmd.Dir)
+	} else {
+		pkg.log.Printf("= GoInstall %s\n%s\n%s\n", cmd.Dir, cmd.Env, cmd.Args)
+	}
+
+	err = cmd.Run()
+
+	return
+}
+
+// GoBuild compile a package recursively ("./...") without installing it.
+func (pkg *Package) GoBuild(envPath string) (err error) {
+	cmd := pkg.goCommand(envPath, "build")
+
+	pkg.log.Printf("= GoBuild %s\n", cmd.Dir)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("GoBuild: %w", err)
+	}
+
+	return err
+}
+
+// GoTest run the tests of package recursively ("./...").
+func (pkg *Package) GoTest(envPath string) (err error) {
+	cmd := pkg.goCommand(envPath, "test")
+
+	pkg.log.Printf("= GoTest %s\n", cmd.Dir)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("GoTest: %w", err)
+	}
+
+	return err
+}
+
+// goCommand create "go" command with sub command (for example, "install")
+// that run recursively ("./...") inside package directory, in GOPATH mode.
+func (pkg *Package) goCommand(envPath, subcmd string) (cmd *exec.Cmd) {
+	cmd = pkg.command("go", subcmd)
+	if pkg.log.IsDebug() {
 		cmd.Args = append(cmd.Args, "-v")
 	}
 	cmd.Args = append(cmd.Args, "./...")
@@ -436,23 +614,40 @@ func (pkg *Package) GoInstall(envPath string) (err error) {
 	envHOME := os.Getenv("HOME")
 
 	cmd.Env = append(cmd.Env, "GO111MODULE=off")
-	cmd.Env = append(cmd.Env, "GOPATH="+build.Default.GOPATH)
+	cmd.Env = append(cmd.Env, pkg.goEnviron()...)
 	cmd.Env = append(cmd.Env, "PATH="+envPath)
 	cmd.Env = append(cmd.Env, "GOCACHE="+envGOCACHE)
 	cmd.Env = append(cmd.Env, "HOME="+envHOME)
 	cmd.Dir = pkg.FullPath
-	cmd.Stdout = defStdout
-	cmd.Stderr = defStderr
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
 
-	if debug.Value == 0 {
-		fmt.Printf("= GoInstall %s\n", cmd.Dir)
-	} else {
-		fmt.Printf("= GoInstall %s\n%s\n%s\n", cmd.Dir, cmd.Env, cmd.Args)
+	return cmd
+}
+
+// goEnviron return the Go environment variables that is set by environment,
+// or the GOPATH from user system if package is created outside of
+// environment.
+func (pkg *Package) goEnviron() []string {
+	if len(pkg.goEnv) == 0 {
+		return []string{"GOPATH=" + build.Default.GOPATH}
 	}
+	return pkg.goEnv
+}
 
-	err = cmd.Run()
+// command create new command that is killed when the package context is
+// done.
+func (pkg *Package) command(name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(pkg.context(), name, args...)
+}
 
-	return
+// context return the package context, or background context if its not
+// set.
+func (pkg *Package) context() context.Context {
+	if pkg.ctx == nil {
+		return context.Background()
+	}
+	return pkg.ctx
 }
 
 // String return formatted output of the package instance.
@@ -481,11 +676,18 @@ func (pkg *Package) String() string {
 
 // Update the current package to the new package. The new package may contain
 // new remote or new version.
+// The new version is verified before the package is changed, if the package
+// require signature.
 func (pkg *Package) Update(newPkg *Package) (err error) {
+	err = pkg.verifyRemote(newPkg.vcsRemoteURL(), newPkg.Version)
+	if err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+
 	if pkg.ImportPath != newPkg.ImportPath {
 		err = os.Rename(pkg.FullPath, newPkg.FullPath)
 		if err != nil {
-			err = fmt.Errorf("Update: %s", err)
+			err = fmt.Errorf("Update: %w", err)
 			return
 		}
 
@@ -512,7 +714,7 @@ func (pkg *Package) Update(newPkg *Package) (err error) {
 	pkg.Version = newPkg.Version
 	pkg.isTag = IsTagVersion(newPkg.Version)
 
-	return nil
+	return pkg.resolveHash()
 }
 
 // UpdateMissingDep will remove missing package if it's already provided by
@@ -547,6 +749,50 @@ func (pkg *Package) UpdateMissingDep(newPkg *Package, addAsDep bool) (found bool
 	return
 }
 
+// verifyRemote verify the signature of version from repository at URL,
+// before the package remote, directory, or working tree is changed.
+// It will return an error ErrUnsigned if the package require signature and
+// the version is not signed.
+func (pkg *Package) verifyRemote(url, version string) (err error) {
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitVerifyRemote(url, version)
+	}
+	return err
+}
+
+// resolveHash set the package CommitHash and TreeHash from the current
+// package Version.
+// If package Version is empty, it will return nil.
+func (pkg *Package) resolveHash() (err error) {
+	if len(pkg.Version) == 0 {
+		return nil
+	}
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitResolveHash()
+	}
+	return err
+}
+
+// verifyHash check that the package Version still resolve to the commit
+// and tree recorded in database.
+// It will return an error ErrVersionMoved if the version has been moved.
+// If no hash has been recorded, it will return nil.
+func (pkg *Package) verifyHash() (err error) {
+	if len(pkg.CommitHash) == 0 && len(pkg.TreeHash) == 0 {
+		return nil
+	}
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitVerifyHash()
+	}
+	return err
+}
+
+// vcsRemoteURL return the remote URL that is used by VCS, after applying
+// the URL rewrite rules.
+func (pkg *Package) vcsRemoteURL() string {
+	return pkg.urlRewrites.rewrite(pkg.RemoteURL)
+}
+
 // pushDep will append import path into list of dependencies only if it's not
 // exist.
 func (pkg *Package) pushDep(importPath string) {
@@ -558,10 +804,8 @@ func (pkg *Package) pushDep(importPath string) {
 
 	pkg.Deps = append(pkg.Deps, importPath)
 
-	if debug.Value >= 2 {
-		fmt.Printf("[PKG] pushDep %s >>> %s\n", pkg.ImportPath,
-			importPath)
-	}
+	pkg.log.Debugf("[PKG] pushDep %s >>> %s\n", pkg.ImportPath,
+		importPath)
 }
 
 // pushMissing import path only if not exist yet.
@@ -5,22 +5,31 @@
 package beku
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/shuLhan/share/lib/debug"
 	"github.com/shuLhan/share/lib/git"
 )
 
 // gitFreeze set the package remote name and URL, branch, and revision.
 func (pkg *Package) gitFreeze() (err error) {
-	err = git.RemoteChange(pkg.FullPath, pkg.RemoteName, pkg.RemoteName,
-		pkg.RemoteURL)
+	err = pkg.gitVerifyRemote(pkg.vcsRemoteURL(), pkg.Version)
+	if err != nil {
+		return fmt.Errorf("gitFreeze: %w", err)
+	}
+
+	err = pkg.gitRemoteChange(pkg.RemoteName, pkg.RemoteName,
+		pkg.vcsRemoteURL())
 	if err != nil {
 		return
 	}
 
-	err = git.FetchAll(pkg.FullPath)
+	err = pkg.gitFetch()
 	if err != nil {
 		return
 	}
@@ -31,28 +40,62 @@ func (pkg *Package) gitFreeze() (err error) {
 		}
 	}
 
-	err = git.CheckoutRevision(pkg.FullPath, pkg.RemoteName,
-		pkg.RemoteBranch, pkg.Version)
+	if len(pkg.UpstreamURL) > 0 {
+		err = pkg.gitSetUpstream()
+		if err != nil {
+			return
+		}
+	}
+
+	err = pkg.verifyHash()
+	if err != nil {
+		return
+	}
+
+	err = pkg.gitCheckoutRevision(pkg.Version)
+	if err != nil {
+		return
+	}
+
+	if len(pkg.CommitHash) == 0 {
+		err = pkg.gitResolveHash()
+	}
 
 	return
 }
 
 // gitInstall the package into source directory.
-func (pkg *Package) gitInstall() (err error) {
+// If mirrorURL is not empty, the package will be cloned from mirror instead
+// of from remote URL.
+func (pkg *Package) gitInstall(mirrorURL string) (err error) {
 	var logp = `gitInstall`
 
-	err = git.Clone(pkg.RemoteURL, pkg.FullPath)
-	if err != nil {
-		return fmt.Errorf(`%s: %w`, logp, err)
+	if len(mirrorURL) == 0 {
+		err = pkg.gitClone(pkg.vcsRemoteURL())
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	} else {
+		err = pkg.gitCloneMirror(mirrorURL)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+
+	if len(pkg.UpstreamURL) > 0 {
+		err = pkg.gitSetUpstream()
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
 	}
 
 	var rev string
 	if len(pkg.Version) == 0 {
-		rev, err = git.LatestTag(pkg.FullPath)
+		rev, err = pkg.gitLatestTag()
 		if len(rev) > 0 && err == nil {
 			pkg.isTag = IsTagVersion(rev)
 		} else {
-			rev, err = git.LatestCommit(pkg.FullPath, "")
+			rev, err = pkg.gitLatestCommit()
 			if err != nil {
 				return fmt.Errorf(`%s: %w`, logp, err)
 			}
@@ -67,40 +110,862 @@ func (pkg *Package) gitInstall() (err error) {
 		}
 	}
 
+	err = pkg.verifyHash()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = pkg.verifySignature(pkg.Version)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
 	if pkg.isTag {
-		err = git.CheckoutRevision(pkg.FullPath, pkg.RemoteName,
-			pkg.RemoteBranch, pkg.Version)
+		err = pkg.gitCheckoutRevision(pkg.Version)
 		if err != nil {
 			return fmt.Errorf(`%s: %w`, logp, err)
 		}
 	}
 
+	err = pkg.gitResolveHash()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
 	return nil
 }
 
+// gitBundle create git bundle file that contains all references in the
+// package repository, including the package version.
+func (pkg *Package) gitBundle(file string) (err error) {
+	cmd := pkg.command("git", "rev-parse", "--verify", "--quiet",
+		pkg.Version+"^{commit}")
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitBundle %s %s\n", cmd.Dir, cmd.Args)
+
+	_, err = cmd.Output()
+	if err != nil {
+		return fmt.Errorf("gitBundle: version %s not found: %w",
+			pkg.Version, err)
+	}
+
+	cmd = pkg.command("git", "bundle", "create")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, file, "--all")
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitBundle %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitBundle: %w", err)
+	}
+
+	return err
+}
+
+// gitChangelog return list of commits between two versions, ordered from
+// the oldest one.
+// Each commit is marked if its changes the declaration of exported
+// identifier on non-test Go files.
+func (pkg *Package) gitChangelog(oldVer, newVer string) (
+	commits []*changelogCommit, err error,
+) {
+	cmd := pkg.command("git", "log", "--reverse", "--no-merges",
+		"--format=%H %s", oldVer+".."+newVer)
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitChangelog %s %s\n", cmd.Dir, cmd.Args)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gitChangelog: %w", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+
+		var hash, subject string
+
+		idx := strings.IndexByte(line, ' ')
+		if idx < 0 {
+			hash = line
+		} else {
+			hash = line[:idx]
+			subject = line[idx+1:]
+		}
+
+		c := newChangelogCommit(hash, subject)
+
+		cmd = pkg.command("git", "show", "--format=", "--unified=0",
+			hash, "--", "*.go", ":(exclude)*_test.go")
+		cmd.Dir = pkg.FullPath
+		cmd.Stderr = pkg.log.Err()
+
+		diff, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("gitChangelog: %w", err)
+		}
+
+		c.exported = isExportedChange(diff)
+
+		commits = append(commits, c)
+	}
+
+	return commits, nil
+}
+
+// gitCloneMirror clone the package from mirror repository, and then set
+// the package remote back to the original remote URL.
+func (pkg *Package) gitCloneMirror(mirrorURL string) (err error) {
+	err = pkg.gitClone(mirrorURL)
+	if err != nil {
+		return err
+	}
+
+	err = pkg.gitRemoteChange(gitDefRemoteName, pkg.RemoteName,
+		pkg.vcsRemoteURL())
+	if err != nil {
+		return err
+	}
+
+	return pkg.gitFetchFrom(mirrorURL)
+}
+
+// gitFetch will fetch the latest commits and tags from remote.
+// If the package have mirror, the commits and tags are fetched from mirror
+// repository instead.
+func (pkg *Package) gitFetch() (err error) {
+	if len(pkg.mirrorURL) == 0 {
+		return pkg.gitFetchAll()
+	}
+	return pkg.gitFetchFrom(pkg.mirrorURL)
+}
+
+// gitFetchFrom fetch the commits and tags from repository at URL into the
+// package remote-tracking branches.
+func (pkg *Package) gitFetchFrom(url string) (err error) {
+	refspec := "+refs/heads/*:refs/remotes/" + pkg.RemoteName + "/*"
+
+	cmd := pkg.command("git", "fetch")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, "--tags", "--force", url, refspec)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitFetchFrom %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitFetchFrom: %w", err)
+	}
+
+	return err
+}
+
+// gitVerifyRemote verify the signature of version from repository at URL,
+// only if the package require it.
+// The tags and the default branch are fetched from URL, or from the package
+// mirror, to check the version without changing the package remote,
+// branches, or working tree.
+func (pkg *Package) gitVerifyRemote(url, version string) (err error) {
+	if !pkg.isSignatureRequired() {
+		return nil
+	}
+	if len(pkg.mirrorURL) > 0 {
+		url = pkg.mirrorURL
+	}
+
+	cmd := pkg.command("git", "fetch")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, "--tags", "--force", url)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitVerifyRemote %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("gitVerifyRemote: %w", err)
+	}
+
+	return pkg.verifySignature(version)
+}
+
+// gitSetUpstream set the remote "upstream" in package repository to the
+// package upstream URL.
+// The upstream remote is skipped by "git fetch --all" and its tags are not
+// fetched into "refs/tags", so the upstream tags does not mixed with the
+// fork tags.
+func (pkg *Package) gitSetUpstream() (err error) {
+	prefix := "remote." + gitUpstreamName + "."
+	configs := [][]string{
+		{prefix + "url", pkg.urlRewrites.rewrite(pkg.UpstreamURL)},
+		{prefix + "fetch", "+refs/heads/*:refs/remotes/" + gitUpstreamName + "/*"},
+		{prefix + "tagOpt", "--no-tags"},
+		{prefix + "skipFetchAll", "true"},
+	}
+
+	for _, kv := range configs {
+		cmd := pkg.command("git", "config", "--replace-all", kv[0], kv[1])
+		cmd.Dir = pkg.FullPath
+		cmd.Stdout = pkg.log.Progress()
+		cmd.Stderr = pkg.log.Err()
+
+		pkg.log.Verbosef("= gitSetUpstream %s %s\n", cmd.Dir, cmd.Args)
+
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("gitSetUpstream: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// gitUpstreamTags return list of tags in upstream repository that has not
+// been merged into the package remote branch, sorted by version in
+// descending order.
+// The upstream tags are listed using "git ls-remote", so nothing is changed
+// in the package repository.
+func (pkg *Package) gitUpstreamTags() (tags []string, err error) {
+	if len(pkg.RemoteBranch) == 0 {
+		err = pkg.gitGetBranch()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	branch := "refs/remotes/" + pkg.RemoteName + "/" + pkg.RemoteBranch
+
+	cmd := pkg.command("git", "ls-remote", "--tags", "--sort=-v:refname",
+		pkg.urlRewrites.rewrite(pkg.UpstreamURL))
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitUpstreamTags %s %s\n", cmd.Dir, cmd.Args)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gitUpstreamTags: %w", err)
+	}
+
+	// The annotated tag is listed twice, the tag object and the commit
+	// that its point to, with suffix "^{}".
+	var (
+		names   []string
+		commits = make(map[string]string)
+	)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		name := strings.TrimPrefix(fields[1], "refs/tags/")
+		peeled := strings.HasSuffix(name, "^{}")
+		name = strings.TrimSuffix(name, "^{}")
+
+		_, ok := commits[name]
+		if !ok {
+			names = append(names, name)
+		}
+		if !ok || peeled {
+			commits[name] = fields[0]
+		}
+	}
+
+	for _, name := range names {
+		// The commit that does not exist in package repository
+		// has not been merged.
+		_, err = pkg.gitRevParse(commits[name] + "^{commit}")
+		if err == nil {
+			var merged bool
+			merged, err = pkg.gitIsAncestor(commits[name], branch)
+			if err != nil {
+				return nil, fmt.Errorf("gitUpstreamTags: %w", err)
+			}
+			if merged {
+				continue
+			}
+		}
+		tags = append(tags, name)
+	}
+
+	return tags, nil
+}
+
+// gitWorktreeAdd checkout the package revision into new worktree at
+// directory dir.
+func (pkg *Package) gitWorktreeAdd(dir, rev string) (err error) {
+	cmd := pkg.command("git", "worktree", "add", "--detach")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, dir, rev)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitWorktreeAdd %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitWorktreeAdd: %w", err)
+	}
+
+	return err
+}
+
+// gitWorktreeRemove remove the worktree at directory dir.
+func (pkg *Package) gitWorktreeRemove(dir string) (err error) {
+	cmd := pkg.command("git", "worktree", "remove", "--force", dir)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitWorktreeRemove %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitWorktreeRemove: %w", err)
+	}
+
+	return err
+}
+
+// gitIsAncestor will return true if the revision ancestor is ancestor of
+// revision rev.
+func (pkg *Package) gitIsAncestor(ancestor, rev string) (ok bool, err error) {
+	cmd := pkg.command("git", "merge-base", "--is-ancestor", ancestor, rev)
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitIsAncestor %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("gitIsAncestor: %w", err)
+}
+
+// gitRevList return list of commits after revision from until revision to,
+// following only the first parent, ordered from the oldest one.
+func (pkg *Package) gitRevList(from, to string) (commits []string, err error) {
+	cmd := pkg.command("git", "rev-list", "--reverse", "--first-parent",
+		from+".."+to)
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitRevList %s %s\n", cmd.Dir, cmd.Args)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gitRevList: %w", err)
+	}
+
+	for _, rev := range strings.Split(string(out), "\n") {
+		rev = strings.TrimSpace(rev)
+		if len(rev) > 0 {
+			commits = append(commits, rev)
+		}
+	}
+
+	return commits, nil
+}
+
+// gitDirtyStatus count the modified or untracked files and the commits
+// that does not exist on remote branches or tags.
+func (pkg *Package) gitDirtyStatus(st *dirtyStatus) (err error) {
+	cmd := pkg.command("git", "status", "--porcelain")
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitDirtyStatus %s %s\n", cmd.Dir, cmd.Args)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("gitDirtyStatus: %w", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if len(line) > 0 {
+			st.files++
+		}
+	}
+
+	cmd = pkg.command("git", "rev-list", "--count", "HEAD", "--not",
+		"--remotes", "--tags")
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitDirtyStatus %s %s\n", cmd.Dir, cmd.Args)
+
+	out, err = cmd.Output()
+	if err != nil {
+		return fmt.Errorf("gitDirtyStatus: %w", err)
+	}
+
+	st.commits, err = strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return fmt.Errorf("gitDirtyStatus: %w", err)
+	}
+
+	return nil
+}
+
+// gitStash save the modified and untracked files into stash, and the local
+// commits into new branch "beku/<op>-<unix-time>".
+func (pkg *Package) gitStash(st *dirtyStatus, op string) (err error) {
+	name := fmt.Sprintf("beku/%s-%d", strings.ToLower(op), time.Now().Unix())
+
+	var cmds [][]string
+
+	if st.files > 0 {
+		cmds = append(cmds, []string{"git", "stash", "push",
+			"--include-untracked", "--message", name})
+	}
+	if st.commits > 0 {
+		cmds = append(cmds, []string{"git", "branch", name, "HEAD"})
+	}
+
+	for _, args := range cmds {
+		cmd := pkg.command(args[0], args[1:]...)
+		cmd.Dir = pkg.FullPath
+		cmd.Stdout = pkg.log.Progress()
+		cmd.Stderr = pkg.log.Err()
+
+		pkg.log.Verbosef("= gitStash %s %s\n", cmd.Dir, cmd.Args)
+
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("gitStash: %w", err)
+		}
+	}
+
+	st.name = name
+
+	pkg.log.Printf("= gitStash %s >>> local changes saved as %s\n",
+		pkg.ImportPath, name)
+
+	return nil
+}
+
+// gitUnstash apply the local commits from branch and the modified and
+// untracked files from stash that has been saved by gitStash, on top of the
+// current HEAD.
+// The branch and stash are removed once they are restored.
+func (pkg *Package) gitUnstash(st *dirtyStatus) (err error) {
+	if st.commits > 0 {
+		err = pkg.gitUnstashCommits(st.name)
+		if err != nil {
+			if st.files > 0 {
+				err = fmt.Errorf("%w, modified and untracked files are kept in stash %s",
+					err, st.name)
+			}
+			return err
+		}
+	}
+	if st.files > 0 {
+		err = pkg.gitUnstashFiles(st.name)
+	}
+	return err
+}
+
+// gitUnstashCommits cherry-pick the local commits in branch, that does not
+// exist on remote branches or tags, on top of current HEAD.
+// If cherry-pick failed, it will be aborted and the commits are kept in
+// the branch.
+func (pkg *Package) gitUnstashCommits(branch string) (err error) {
+	commits, err := pkg.gitOutput("rev-list", "--reverse", branch, "--not",
+		"--remotes", "--tags")
+	if err != nil {
+		return fmt.Errorf("gitUnstashCommits: %w", err)
+	}
+
+	if len(commits) > 0 {
+		cmd := pkg.command("git", "cherry-pick")
+		cmd.Args = append(cmd.Args, strings.Fields(commits)...)
+		cmd.Dir = pkg.FullPath
+		cmd.Stdout = pkg.log.Progress()
+		cmd.Stderr = pkg.log.Err()
+
+		pkg.log.Verbosef("= gitUnstashCommits %s %s\n", cmd.Dir, cmd.Args)
+
+		err = cmd.Run()
+		if err != nil {
+			_, _ = pkg.gitOutput("cherry-pick", "--abort")
+			return fmt.Errorf("gitUnstashCommits: conflict, local commits are kept in branch %s",
+				branch)
+		}
+	}
+
+	_, err = pkg.gitOutput("branch", "-D", branch)
+	if err != nil {
+		return fmt.Errorf("gitUnstashCommits: %w", err)
+	}
+
+	return nil
+}
+
+// gitUnstashFiles apply the stash with message name on top of current HEAD.
+// If the stash can not be applied, the working tree is reset back to HEAD
+// and the changes are kept in the stash.
+func (pkg *Package) gitUnstashFiles(name string) (err error) {
+	out, err := pkg.gitOutput("stash", "list", "--format=%gd %gs")
+	if err != nil {
+		return fmt.Errorf("gitUnstashFiles: %w", err)
+	}
+
+	var ref string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == name {
+			ref = fields[0]
+			break
+		}
+	}
+	if len(ref) == 0 {
+		return fmt.Errorf("gitUnstashFiles: stash %s not found", name)
+	}
+
+	cmd := pkg.command("git", "stash", "apply", "--quiet", ref)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitUnstashFiles %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		_, _ = pkg.gitOutput("reset", "--quiet", "--hard")
+		_, _ = pkg.gitOutput("clean", "-qdff")
+		return fmt.Errorf("gitUnstashFiles: conflict, modified and untracked files are kept in stash %s",
+			name)
+	}
+
+	_, err = pkg.gitOutput("stash", "drop", "--quiet", ref)
+	if err != nil {
+		return fmt.Errorf("gitUnstashFiles: %w", err)
+	}
+
+	return nil
+}
+
+// gitDrifts return the differences between the package repository and the
+// package version, remote URL, and remote branch.
+func (pkg *Package) gitDrifts() (drifts []string, err error) {
+	head, err := pkg.gitRevParse("HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := pkg.gitRevParse(pkg.Version + "^{commit}")
+	if err != nil {
+		drifts = append(drifts, fmt.Sprintf("version %s not found", pkg.Version))
+	} else if head != rev {
+		drifts = append(drifts, fmt.Sprintf("HEAD is at %s, expecting %s",
+			head, pkg.Version))
+	}
+
+	remoteURL, err := git.GetRemoteURL(pkg.FullPath, pkg.RemoteName)
+	if err != nil {
+		drifts = append(drifts, fmt.Sprintf("remote %s not found",
+			pkg.RemoteName))
+	} else {
+		remoteURL = pkg.urlRewrites.canonical(remoteURL)
+		if remoteURL != pkg.RemoteURL {
+			drifts = append(drifts, fmt.Sprintf(
+				"remote URL is %s, expecting %s", remoteURL,
+				pkg.RemoteURL))
+		}
+	}
+
+	if len(pkg.RemoteBranch) > 0 {
+		branch, err := pkg.gitRevParse("--abbrev-ref", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		if branch != pkg.RemoteBranch {
+			drifts = append(drifts, fmt.Sprintf(
+				"branch is %s, expecting %s", branch,
+				pkg.RemoteBranch))
+		}
+	}
+
+	return drifts, nil
+}
+
+// gitResolveHash set the package CommitHash and TreeHash from the commit
+// and tree of package Version.
+// If one of them can not be resolved, for example the command is killed,
+// both hashes are cleared, so they will be resolved again on the next sync
+// instead of reporting the version as moved.
+func (pkg *Package) gitResolveHash() (err error) {
+	commit, err := pkg.gitRevParse(pkg.Version + "^{commit}")
+	if err == nil {
+		var tree string
+		tree, err = pkg.gitRevParse(pkg.Version + "^{tree}")
+		if err == nil {
+			pkg.CommitHash = commit
+			pkg.TreeHash = tree
+			return nil
+		}
+	}
+
+	pkg.CommitHash = ""
+	pkg.TreeHash = ""
+
+	return fmt.Errorf("gitResolveHash: %w", err)
+}
+
+// gitVerifyHash compare the commit and tree of package Version with the
+// package CommitHash and TreeHash.
+func (pkg *Package) gitVerifyHash() (err error) {
+	commit, err := pkg.gitRevParse(pkg.Version + "^{commit}")
+	if err != nil {
+		return fmt.Errorf("gitVerifyHash: %w", err)
+	}
+	if len(pkg.CommitHash) > 0 && commit != pkg.CommitHash {
+		return fmt.Errorf("%s: %w: %s point to commit %s, expecting %s",
+			pkg.ImportPath, ErrVersionMoved, pkg.Version, commit,
+			pkg.CommitHash)
+	}
+
+	tree, err := pkg.gitRevParse(pkg.Version + "^{tree}")
+	if err != nil {
+		return fmt.Errorf("gitVerifyHash: %w", err)
+	}
+	if len(pkg.TreeHash) > 0 && tree != pkg.TreeHash {
+		return fmt.Errorf("%s: %w: %s point to tree %s, expecting %s",
+			pkg.ImportPath, ErrVersionMoved, pkg.Version, tree,
+			pkg.TreeHash)
+	}
+
+	return nil
+}
+
+// gitVerifySignature verify the signature of tag or commit of version.
+// If version is a tag, the tag signature is verified first; if the tag is
+// not signed, the commit that the tag point to is verified.
+func (pkg *Package) gitVerifySignature(version string) (err error) {
+	var (
+		args, envs []string
+		stderr     bytes.Buffer
+	)
+	if pkg.signature != nil {
+		args, envs, err = pkg.signature.gitConfig()
+		if err != nil {
+			return fmt.Errorf("gitVerifySignature: %w", err)
+		}
+	}
+
+	verify := func(subcmd, rev string) error {
+		cmd := pkg.command("git", args...)
+		cmd.Args = append(cmd.Args, subcmd, rev)
+		cmd.Dir = pkg.FullPath
+		cmd.Env = append(os.Environ(), envs...)
+		cmd.Stderr = &stderr
+
+		pkg.log.Verbosef("= gitVerifySignature %s %s\n", cmd.Dir, cmd.Args)
+
+		return cmd.Run()
+	}
+
+	if IsTagVersion(version) {
+		err = verify("verify-tag", version)
+		if err == nil {
+			return nil
+		}
+	}
+
+	err = verify("verify-commit", version+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s: %s", pkg.ImportPath, ErrUnsigned,
+			version, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// gitNearestTag return the nearest semantic version tag that is reachable
+// from version.
+// If no tag found, it will return empty string.
+func (pkg *Package) gitNearestTag(version string) (tag string, err error) {
+	cmd := pkg.command("git", "describe", "--tags", "--abbrev=0",
+		"--match=v[0-9]*", version)
+	cmd.Dir = pkg.FullPath
+
+	pkg.log.Verbosef("= gitNearestTag %s %s\n", cmd.Dir, cmd.Args)
+
+	b, err := cmd.Output()
+	if err != nil {
+		// No tag found or version is not exist.
+		return "", nil
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// gitPseudoVersion return the pseudo-version of commit at version, based on
+// the nearest tag before it, as defined by Go modules,
+//
+//	v0.0.0-yyyymmddhhmmss-abcdefabcdef        (no tag)
+//	vX.Y.(Z+1)-0.yyyymmddhhmmss-abcdefabcdef  (tag vX.Y.Z)
+//	vX.Y.Z-pre.0.yyyymmddhhmmss-abcdefabcdef  (tag vX.Y.Z-pre)
+//
+// If the version is the tagged commit, it will return the tag.
+func (pkg *Package) gitPseudoVersion(version string) (pseudo string, err error) {
+	logp := "gitPseudoVersion"
+
+	commit, err := pkg.gitRevParse(version + "^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", logp, err)
+	}
+
+	out, err := pkg.gitOutput("log", "-1", "--format=%ct", commit)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s: %w", logp, version, err)
+	}
+	sec, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s: %w", logp, version, err)
+	}
+
+	suffix := time.Unix(sec, 0).UTC().Format("20060102150405") + "-" +
+		commit[:12]
+
+	tag, _ := pkg.gitNearestTag(commit)
+	if idx := strings.IndexByte(tag, '+'); idx >= 0 {
+		tag = tag[:idx]
+	}
+
+	nums := strings.Split(strings.TrimPrefix(tag, "v"), ".")
+	if len(nums) < 3 {
+		return "v0.0.0-" + suffix, nil
+	}
+
+	tagCommit, err := pkg.gitRevParse(tag + "^{commit}")
+	if err == nil && tagCommit == commit {
+		return tag, nil
+	}
+
+	if strings.IndexByte(tag, '-') > 0 {
+		return tag + ".0." + suffix, nil
+	}
+
+	patch, err := strconv.Atoi(nums[2])
+	if err != nil {
+		return "v0.0.0-" + suffix, nil
+	}
+
+	return fmt.Sprintf("v%s.%s.%d-0.%s", nums[0], nums[1], patch+1,
+		suffix), nil
+}
+
+// gitLocalRemoteName return the name of remote in repository at dir, that
+// is the remote of current branch, the remote "origin", or the first
+// remote, in that order.
+// If repository does not have any remote, it will return empty string.
+func gitLocalRemoteName(dir string) (name string) {
+	output := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		b, err := cmd.Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	branch := output("symbolic-ref", "--quiet", "--short", "HEAD")
+	if len(branch) > 0 {
+		name = output("config", "branch."+branch+".remote")
+		if len(name) > 0 && name != "." {
+			return name
+		}
+	}
+
+	remotes := strings.Fields(output("remote"))
+	for _, remote := range remotes {
+		if remote == gitDefRemoteName {
+			return remote
+		}
+	}
+	if len(remotes) > 0 {
+		return remotes[0]
+	}
+
+	return ""
+}
+
+// gitRevParse return the output of "git rev-parse" with arguments.
+func (pkg *Package) gitRevParse(args ...string) (out string, err error) {
+	cmd := pkg.command("git", "rev-parse", "--verify", "--quiet")
+	cmd.Args = append(cmd.Args, args...)
+	cmd.Dir = pkg.FullPath
+
+	pkg.log.Verbosef("= gitRevParse %s %s\n", cmd.Dir, cmd.Args)
+
+	b, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("gitRevParse %s: %w", args, err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
 // gitScan will scan the package version and remote URL.
 func (pkg *Package) gitScan() (err error) {
-	pkg.Version, err = git.LatestVersion(pkg.FullPath)
+	pkg.Version, err = pkg.gitLatestVersion()
 	if err != nil {
-		err = fmt.Errorf("gitScan: %s", err)
+		err = fmt.Errorf("gitScan: %w", err)
 		return
 	}
 
-	pkg.RemoteURL, err = git.GetRemoteURL(pkg.FullPath, "")
+	pkg.RemoteURL, err = git.GetRemoteURL(pkg.FullPath, pkg.RemoteName)
 	if err != nil {
-		err = fmt.Errorf("gitScan: %s", err)
+		err = fmt.Errorf("gitScan: %w", err)
 		return
 	}
+	pkg.RemoteURL = pkg.urlRewrites.canonical(pkg.RemoteURL)
 
 	err = pkg.gitGetBranch()
+	if err != nil {
+		return
+	}
+
+	err = pkg.gitResolveHash()
 
 	return
 }
 
 func (pkg *Package) gitGetBranch() (err error) {
-	branches, err := git.RemoteBranches(pkg.FullPath)
+	branches, err := pkg.gitRemoteBranches()
 	if err != nil {
-		err = fmt.Errorf("gitGetBranch: %s", err)
+		err = fmt.Errorf("gitGetBranch: %w", err)
 		return
 	}
 
@@ -130,23 +995,23 @@ func (pkg *Package) gitGetBranch() (err error) {
 	} else if len(branches) > 0 {
 		pkg.RemoteBranch = branches[len(branches)-1]
 	}
-	if debug.Value >= 1 {
-		fmt.Printf("= gitGetBranch: %s\n", pkg.RemoteBranch)
-	}
+	pkg.log.Verbosef("= gitGetBranch: %s\n", pkg.RemoteBranch)
 	return nil
 }
 
 // gitUpdate will change the currrent package remote name, URL, or version
 // based on new package information.
+// The signature of new version must be verified before, using
+// gitVerifyRemote.
 func (pkg *Package) gitUpdate(newPkg *Package) (err error) {
 	if pkg.RemoteName != newPkg.RemoteName || pkg.RemoteURL != newPkg.RemoteURL {
-		err = git.RemoteChange(pkg.FullPath, pkg.RemoteName,
-			newPkg.RemoteName, newPkg.RemoteURL)
+		err = pkg.gitRemoteChange(pkg.RemoteName,
+			newPkg.RemoteName, newPkg.vcsRemoteURL())
 		if err != nil {
 			return
 		}
 
-		err = git.FetchAll(pkg.FullPath)
+		err = pkg.gitFetch()
 		if err != nil {
 			return
 		}
@@ -159,11 +1024,230 @@ func (pkg *Package) gitUpdate(newPkg *Package) (err error) {
 		}
 	}
 
-	err = git.CheckoutRevision(pkg.FullPath, pkg.RemoteName,
-		pkg.RemoteBranch, newPkg.Version)
+	err = pkg.gitCheckoutRevision(newPkg.Version)
 	if err != nil {
-		err = fmt.Errorf("gitUpdate: %s", err)
+		err = fmt.Errorf("gitUpdate: %w", err)
 	}
 
 	return
 }
+
+// gitCheckoutRevision set the HEAD to revision rev on the package remote
+// branch.
+// Any untracked files and directories will be removed before checking
+// out.
+// If rev is empty, it will do nothing.
+func (pkg *Package) gitCheckoutRevision(rev string) (err error) {
+	if len(rev) == 0 {
+		return nil
+	}
+
+	remoteName := pkg.RemoteName
+	if len(remoteName) == 0 {
+		remoteName = gitDefRemoteName
+	}
+	branch := pkg.RemoteBranch
+	if len(branch) == 0 {
+		branch = gitDefBranch
+	}
+
+	var quiet []string
+	if !pkg.log.IsVerbose() {
+		quiet = []string{"--quiet"}
+	}
+
+	cmds := [][]string{
+		{"git", "clean", "-qdff"},
+		append([]string{"git", "checkout"}, append(quiet, "--track",
+			remoteName+"/"+branch, "-B", branch)...),
+		append([]string{"git", "reset"}, append(quiet, "--hard", rev)...),
+	}
+
+	for _, args := range cmds {
+		cmd := pkg.command(args[0], args[1:]...)
+		cmd.Dir = pkg.FullPath
+		cmd.Stdout = pkg.log.Progress()
+		cmd.Stderr = pkg.log.Err()
+
+		pkg.log.Verbosef("= gitCheckoutRevision %s %s\n", cmd.Dir, cmd.Args)
+
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("gitCheckoutRevision: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// gitClone clone the repository at url into package directory.
+// If package directory is not empty it will return an error.
+func (pkg *Package) gitClone(url string) (err error) {
+	err = os.MkdirAll(pkg.FullPath, 0700)
+	if err != nil {
+		return fmt.Errorf("gitClone: %w", err)
+	}
+
+	cmd := pkg.command("git", "clone")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, url, ".")
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitClone %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitClone: %w", err)
+	}
+
+	return err
+}
+
+// gitFetchAll fetch the latest commits and tags from all remotes.
+func (pkg *Package) gitFetchAll() (err error) {
+	cmd := pkg.command("git", "fetch")
+	if !pkg.log.IsVerbose() {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Args = append(cmd.Args, "--all", "--tags", "--force")
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Progress()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitFetchAll %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitFetchAll: %w", err)
+	}
+
+	return err
+}
+
+// gitLatestCommit return the latest commit hash, in short format, on the
+// default remote branch.
+func (pkg *Package) gitLatestCommit() (commit string, err error) {
+	commit, err = pkg.gitOutput("rev-parse", "--short",
+		gitDefRemoteName+"/"+gitDefBranch)
+	if err != nil {
+		return "", fmt.Errorf("gitLatestCommit: %w", err)
+	}
+	return commit, nil
+}
+
+// gitLatestTag return the latest tag, or empty string if repository does
+// not have any tag.
+func (pkg *Package) gitLatestTag() (tag string, err error) {
+	rev, err := pkg.gitOutput("rev-list", "--tags", "--max-count=1")
+	if err != nil {
+		return "", fmt.Errorf("gitLatestTag: %w", err)
+	}
+	if len(rev) == 0 {
+		return "", nil
+	}
+
+	tag, err = pkg.gitOutput("describe", "--tags", "--abbrev=0", rev)
+	if err != nil {
+		return "", fmt.Errorf("gitLatestTag: %w", err)
+	}
+
+	return tag, nil
+}
+
+// gitLatestVersion return the latest tag, or the latest commit if
+// repository does not have any tag.
+func (pkg *Package) gitLatestVersion() (version string, err error) {
+	version, err = pkg.gitLatestTag()
+	if err == nil && len(version) > 0 {
+		return version, nil
+	}
+
+	version, err = pkg.gitLatestCommit()
+	if err != nil {
+		return "", fmt.Errorf("gitLatestVersion: %w", err)
+	}
+
+	return version, nil
+}
+
+// gitLogRevisions print the commits between two revisions.
+func (pkg *Package) gitLogRevisions(prevRev, nextRev string) (err error) {
+	cmd := pkg.command("git", "--no-pager", "log", "--oneline",
+		prevRev+"..."+nextRev)
+	cmd.Dir = pkg.FullPath
+	cmd.Stdout = pkg.log.Out()
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitLogRevisions %s %s\n", cmd.Dir, cmd.Args)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("gitLogRevisions: %w", err)
+	}
+
+	return err
+}
+
+// gitRemoteBranches return list of remote branches, excluding "HEAD".
+func (pkg *Package) gitRemoteBranches() (branches []string, err error) {
+	out, err := pkg.gitOutput("--no-pager", "branch", "-r", "--format",
+		"%(refname:lstrip=3)")
+	if err != nil {
+		return nil, fmt.Errorf("gitRemoteBranches: %w", err)
+	}
+
+	for _, branch := range strings.Split(out, "\n") {
+		if len(branch) == 0 || branch == "HEAD" {
+			continue
+		}
+		branches = append(branches, branch)
+	}
+
+	return branches, nil
+}
+
+// gitRemoteChange replace the remote oldName in package repository with
+// remote newName and URL newURL.
+func (pkg *Package) gitRemoteChange(oldName, newName, newURL string) (err error) {
+	cmds := [][]string{
+		{"git", "remote", "remove", oldName},
+		{"git", "remote", "add", newName, newURL},
+	}
+
+	for _, args := range cmds {
+		cmd := pkg.command(args[0], args[1:]...)
+		cmd.Dir = pkg.FullPath
+		cmd.Stdout = pkg.log.Progress()
+		cmd.Stderr = pkg.log.Err()
+
+		pkg.log.Verbosef("= gitRemoteChange %s %s\n", cmd.Dir, cmd.Args)
+
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("gitRemoteChange: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// gitOutput run git command with arguments inside package directory and
+// return its output without leading and trailing spaces.
+func (pkg *Package) gitOutput(args ...string) (out string, err error) {
+	cmd := pkg.command("git", args...)
+	cmd.Dir = pkg.FullPath
+	cmd.Stderr = pkg.log.Err()
+
+	pkg.log.Verbosef("= gitOutput %s %s\n", cmd.Dir, cmd.Args)
+
+	b, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
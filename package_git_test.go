@@ -5,6 +5,11 @@
 package beku
 
 import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/shuLhan/share/lib/test"
@@ -64,3 +69,176 @@ func TestGitScanDeps(t *testing.T) {
 		test.Assert(t, "env.pkgsMissing", c.expPkgsMissing, testEnv.pkgsMissing)
 	}
 }
+
+// testGit run git command inside directory with fixed author and committer
+// date, so the tag order is predictable.
+func testGit(t *testing.T, dir, date string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=beku", "GIT_AUTHOR_EMAIL=beku@localhost",
+		"GIT_COMMITTER_NAME=beku", "GIT_COMMITTER_EMAIL=beku@localhost",
+		"GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s: %s", args, err, out)
+	}
+}
+
+func TestGitUpstreamTags(t *testing.T) {
+	dir := t.TempDir()
+	upstreamDir := filepath.Join(dir, "upstream")
+	forkRemoteDir := filepath.Join(dir, "fork.git")
+	forkDir := filepath.Join(dir, "fork")
+
+	err := os.MkdirAll(upstreamDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGit(t, upstreamDir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	testGit(t, upstreamDir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "first")
+	testGit(t, upstreamDir, "2018-01-01T00:00:00Z", "tag", "v0.1.0")
+	testGit(t, dir, "2018-01-02T00:00:00Z", "clone", "--quiet", "--bare",
+		upstreamDir, forkRemoteDir)
+	testGit(t, dir, "2018-01-02T00:00:00Z", "clone", "--quiet",
+		forkRemoteDir, forkDir)
+	testGit(t, forkDir, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "fork")
+	testGit(t, forkDir, "2018-01-02T00:00:00Z", "push", "--quiet")
+	testGit(t, upstreamDir, "2018-01-03T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "second")
+	testGit(t, upstreamDir, "2018-01-03T00:00:00Z", "tag", "v0.2.0")
+	testGit(t, upstreamDir, "2018-01-04T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "third")
+	testGit(t, upstreamDir, "2018-01-04T00:00:00Z", "tag", "v0.3.0")
+
+	pkg := &Package{
+		ImportPath:   "example.com/fork",
+		FullPath:     forkDir,
+		RemoteName:   gitDefRemoteName,
+		RemoteBranch: gitDefBranch,
+		UpstreamURL:  upstreamDir,
+		vcsMode:      VCSModeGit,
+	}
+
+	got, err := pkg.gitUpstreamTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "UpstreamTags", []string{"v0.3.0", "v0.2.0"}, got)
+
+	// Listing the upstream tags must not change the fork repository.
+	remotes, err := pkg.gitOutput("remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "fork remotes", gitDefRemoteName, remotes)
+
+	refs, err := pkg.gitOutput("for-each-ref", "--format=%(refname)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "fork refs", "refs/heads/master\n"+
+		"refs/remotes/origin/HEAD\nrefs/remotes/origin/master\n"+
+		"refs/tags/v0.1.0", refs)
+}
+
+func TestGitRevList(t *testing.T) {
+	dir := t.TempDir()
+
+	testGit(t, dir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	for _, msg := range []string{"first", "second", "third"} {
+		testGit(t, dir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+			"--allow-empty", "-m", msg)
+		testGit(t, dir, "2018-01-01T00:00:00Z", "tag", msg)
+	}
+
+	pkg := &Package{
+		FullPath: dir,
+		vcsMode:  VCSModeGit,
+	}
+
+	commits, err := pkg.gitRevList("first", "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "len(commits)", 2, len(commits))
+
+	cmd := exec.Command("git", "rev-parse", "second")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "commits[0]", strings.TrimSpace(string(out)), commits[0])
+
+	ok, err := pkg.gitIsAncestor("first", "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "gitIsAncestor first third", true, ok)
+
+	ok, err = pkg.gitIsAncestor("third", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "gitIsAncestor third first", false, ok)
+}
+
+func TestGitVerifyHash(t *testing.T) {
+	dir := t.TempDir()
+
+	testGit(t, dir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	testGit(t, dir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "first")
+	testGit(t, dir, "2018-01-01T00:00:00Z", "tag", "v0.1.0")
+
+	pkg := &Package{
+		FullPath: dir,
+		Version:  "v0.1.0",
+		vcsMode:  VCSModeGit,
+	}
+
+	err := pkg.resolveHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "len(CommitHash)", 40, len(pkg.CommitHash))
+	test.Assert(t, "len(TreeHash)", 40, len(pkg.TreeHash))
+
+	err = pkg.verifyHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGit(t, dir, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "second")
+	testGit(t, dir, "2018-01-02T00:00:00Z", "tag", "--force", "v0.1.0")
+
+	err = pkg.verifyHash()
+	test.Assert(t, "errors.Is ErrVersionMoved", true,
+		errors.Is(err, ErrVersionMoved))
+}
+
+func TestGitResolveHashFailed(t *testing.T) {
+	pkg := testCreateDirtyRepo(t)
+	pkg.Version = "v0.1.0"
+	pkg.CommitHash = "0d58f3dd6d960165a90824bc74ebea96368c7c04"
+	pkg.TreeHash = "9ff5049ab658326cc101673605524eb154a10721"
+
+	err := pkg.resolveHash()
+	test.Assert(t, "error", true, err != nil)
+
+	test.Assert(t, "CommitHash", "", pkg.CommitHash)
+	test.Assert(t, "TreeHash", "", pkg.TreeHash)
+}
@@ -34,7 +34,7 @@ func TestPackageRemove(t *testing.T) {
 		t.Log(c.desc)
 
 		if len(c.pkgName) > 0 {
-			c.pkg, _ = NewPackage(testEnv.dirSrc, c.pkgName, c.pkgName)
+			c.pkg, _ = NewPackage(testEnv.log, testEnv.dirSrc, c.pkgName, c.pkgName)
 		}
 
 		var err error
@@ -83,7 +83,7 @@ func TestPackageInstall(t *testing.T) {
 	}, {
 		desc:   `Install again`,
 		pkg:    testGitPkgInstall,
-		expErr: `Install: gitInstall: Clone: exit status 128`,
+		expErr: `Install: gitInstall: gitClone: exit status 128`,
 	}}
 
 	for _, c := range cases {
@@ -100,6 +100,74 @@ func TestPackageInstall(t *testing.T) {
 	}
 }
 
+func TestNewPackageLocal(t *testing.T) {
+	gopathSrc := t.TempDir()
+	forkImportPath := "example.com/fork"
+	forkDir := filepath.Join(gopathSrc, forkImportPath)
+
+	err := os.MkdirAll(forkDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, forkDir, "", "init", "--quiet")
+	testGit(t, forkDir, "", "remote", "add", "fork",
+		"https://example.com/fork")
+
+	cases := []struct {
+		desc       string
+		gopathSrc  string
+		importPath string
+		expErr     string
+		expPkg     *Package
+	}{{
+		desc:       `Package is not exist`,
+		gopathSrc:  testEnv.dirSrc,
+		importPath: testPkgNotExist,
+		expErr: `NewPackageLocal: directory "` +
+			filepath.Join(testEnv.dirSrc, testPkgNotExist) +
+			`" is not using a known version control system`,
+	}, {
+		desc:       `Package exist`,
+		gopathSrc:  testEnv.dirSrc,
+		importPath: testGitRepo,
+		expPkg: &Package{
+			ImportPath: testGitRepo,
+			FullPath:   filepath.Join(testEnv.dirSrc, testGitRepo),
+			RemoteName: gitDefRemoteName,
+			RemoteURL:  testGitRepoSrcLocal,
+			vcsMode:    VCSModeGit,
+			state:      packageStateNew,
+			log:        testEnv.log,
+		},
+	}, {
+		desc:       `With remote other than origin`,
+		gopathSrc:  gopathSrc,
+		importPath: forkImportPath,
+		expPkg: &Package{
+			ImportPath: forkImportPath,
+			FullPath:   forkDir,
+			RemoteName: "fork",
+			RemoteURL:  "https://example.com/fork",
+			vcsMode:    VCSModeGit,
+			state:      packageStateNew,
+			log:        testEnv.log,
+		},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		got, err := NewPackageLocal(testEnv.log, c.gopathSrc,
+			c.importPath)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "Package", c.expPkg, got)
+	}
+}
+
 func TestIsEqual(t *testing.T) {
 	cases := []struct {
 		desc  string
@@ -464,11 +532,13 @@ func TestGoInstall(t *testing.T) {
 	}{{
 		desc:      "Running #1",
 		pkg:       testGitPkgCur,
+		expStdout: "= GoInstall " + testGitPkgCur.FullPath,
 		expStderr: `go: warning: "./..." matched no packages`,
 	}, {
 		desc:      "Running with verbose",
 		pkg:       testGitPkgCur,
 		isVerbose: true,
+		expStdout: "= GoInstall " + testGitPkgCur.FullPath,
 		expStderr: `go: warning: "./..." matched no packages`,
 	}}
 
@@ -592,6 +662,8 @@ func TestUpdate(t *testing.T) {
 			RemoteURL:    testGitRepoSrcLocal,
 			RemoteBranch: "master",
 			Version:      "v0.1.0",
+			CommitHash:   "dcfb688e2b8b90f045fbdf4cb10468354d3cd566",
+			TreeHash:     "f0fd19436c3d8e4f1dbb6dcf770915b35d2fb8e4",
 			isTag:        true,
 		},
 	}, {
@@ -745,7 +817,7 @@ func TestPackageGoClean(t *testing.T) {
 		t.Log(c.desc)
 
 		if len(c.pkgName) > 0 {
-			c.pkg, _ = NewPackage(testEnv.dirSrc, c.pkgName, c.pkgName)
+			c.pkg, _ = NewPackage(testEnv.log, testEnv.dirSrc, c.pkgName, c.pkgName)
 		}
 
 		err = c.pkg.GoClean()
@@ -0,0 +1,144 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Prompter define an interface to ask user for confirmation, for example
+// before viewing commit logs or removing package.
+type Prompter interface {
+	// Confirm ask question msg and return true if the answer is yes.
+	// If no answer is given, it will return defIsYes.
+	Confirm(msg string, defIsYes bool) bool
+}
+
+// ReaderPrompter print the question to writer and read the answer from
+// reader, for example os.Stdout and os.Stdin.
+type ReaderPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewStdinPrompter create new prompter that print the question to standard
+// output and read the answer from standard input.
+func NewStdinPrompter() *ReaderPrompter {
+	return NewReaderPrompter(os.Stdin, os.Stdout)
+}
+
+// NewReaderPrompter create new prompter that print the question to out and
+// read the answer from in.
+// If out is nil, the question is not printed.
+func NewReaderPrompter(in io.Reader, out io.Writer) *ReaderPrompter {
+	if out == nil {
+		out = io.Discard
+	}
+	// The reader is buffered once, so the answers that has been read
+	// but not consumed by one question are not lost for the next one.
+	return &ReaderPrompter{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// Confirm print the question and read the answer, "y" or "n", from reader.
+// Only the first non-space character on the line is used as answer.
+// If the line is empty or the reader return an error, it will return
+// defIsYes.
+func (p *ReaderPrompter) Confirm(msg string, defIsYes bool) bool {
+	var answer byte
+
+	yon := "[y/N]"
+	if defIsYes {
+		yon = "[Y/n]"
+	}
+
+	fmt.Fprintf(p.out, "%s %s ", msg, yon)
+
+	for {
+		b, err := p.in.ReadByte()
+		if err != nil {
+			fmt.Fprintln(p.out)
+			break
+		}
+		if b == '\n' {
+			break
+		}
+		if b == ' ' || b == '\t' || b == '\r' {
+			continue
+		}
+		if answer == 0 {
+			answer = b
+		}
+	}
+
+	switch answer {
+	case 0:
+		return defIsYes
+	case 'y', 'Y':
+		return true
+	}
+	return false
+}
+
+// YesPrompter answer yes to all questions.
+type YesPrompter struct{}
+
+// Confirm always return true.
+func (YesPrompter) Confirm(msg string, defIsYes bool) bool {
+	return true
+}
+
+// NoPrompter answer no to all questions.
+type NoPrompter struct{}
+
+// Confirm always return false.
+func (NoPrompter) Confirm(msg string, defIsYes bool) bool {
+	return false
+}
+
+// ScriptedPrompter answer the questions using list of predefined answers,
+// in order.
+// If all answers has been used, it will return the default answer.
+// All questions that has been asked are recorded in Questions.
+type ScriptedPrompter struct {
+	Questions []string
+	answers   []bool
+}
+
+// NewScriptedPrompter create new prompter with list of answers.
+func NewScriptedPrompter(answers ...bool) *ScriptedPrompter {
+	return &ScriptedPrompter{
+		answers: answers,
+	}
+}
+
+// Confirm return the next answer.
+func (p *ScriptedPrompter) Confirm(msg string, defIsYes bool) (ok bool) {
+	p.Questions = append(p.Questions, msg)
+
+	if len(p.answers) == 0 {
+		return defIsYes
+	}
+
+	ok = p.answers[0]
+	p.answers = p.answers[1:]
+
+	return ok
+}
+
+// confirm ask the question using the environment prompter, or print the
+// question to environment output and read the answer from standard input if
+// its not set.
+func (env *Env) confirm(msg string, defIsYes bool) bool {
+	if env.Prompter == nil {
+		env.Prompter = NewReaderPrompter(os.Stdin, env.log.Out())
+	}
+	return env.Prompter.Confirm(msg, defIsYes)
+}
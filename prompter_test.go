@@ -0,0 +1,82 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestPrompter(t *testing.T) {
+	var out bytes.Buffer
+
+	cases := []struct {
+		desc     string
+		prompter Prompter
+		defIsYes bool
+		exp      []bool
+	}{{
+		desc:     "With YesPrompter",
+		prompter: YesPrompter{},
+		exp:      []bool{true, true},
+	}, {
+		desc:     "With NoPrompter",
+		prompter: NoPrompter{},
+		defIsYes: true,
+		exp:      []bool{false, false},
+	}, {
+		desc:     "With ScriptedPrompter",
+		prompter: NewScriptedPrompter(false),
+		defIsYes: true,
+		exp:      []bool{false, true},
+	}, {
+		desc:     "With ReaderPrompter",
+		prompter: NewReaderPrompter(strings.NewReader("y\ny\n"), nil),
+		exp:      []bool{true, true},
+	}, {
+		desc:     "With ReaderPrompter and default answer",
+		prompter: NewReaderPrompter(strings.NewReader(" n \n\n"), &out),
+		defIsYes: true,
+		exp:      []bool{false, true},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		got := []bool{
+			c.prompter.Confirm("first?", c.defIsYes),
+			c.prompter.Confirm("second?", c.defIsYes),
+		}
+
+		test.Assert(t, "answers", c.exp, got)
+	}
+
+	test.Assert(t, "questions", "first? [Y/n] second? [Y/n] ", out.String())
+}
+
+func TestEnvRemoveWithPrompter(t *testing.T) {
+	pkg := &Package{
+		ImportPath: "github.com/shuLhan/removed",
+		RemoteURL:  "https://github.com/shuLhan/removed",
+	}
+
+	prompter := NewScriptedPrompter(false)
+
+	env := &Env{
+		pkgs:     []*Package{pkg},
+		Prompter: prompter,
+	}
+
+	err := env.Remove(pkg.ImportPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, "Questions", []string{msgContinue}, prompter.Questions)
+	test.Assert(t, "pkgs", []*Package{pkg}, env.pkgs)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"strings"
+)
+
+const sepRewrite = "=>"
+
+// urlRewrite define a rule to replace the prefix of package remote URL with
+// another URL, similar to "url.<base>.insteadOf" in git configuration.
+//
+// The rule is defined in "[beku]" section of database with the following
+// format,
+//
+//	rewrite = <canonical URL prefix> => <rewritten URL prefix>
+//
+// For example,
+//
+//	rewrite = https://github.com/ => git@git.internal:github/
+type urlRewrite struct {
+	from string
+	to   string
+}
+
+// parseURLRewrite parse the rule from string "<from> => <to>".
+func parseURLRewrite(v string) (rule *urlRewrite, err error) {
+	idx := strings.Index(v, sepRewrite)
+	if idx < 0 {
+		return nil, fmt.Errorf("parseURLRewrite: missing %q in %q",
+			sepRewrite, v)
+	}
+
+	rule = &urlRewrite{
+		from: strings.TrimSpace(v[:idx]),
+		to:   strings.TrimSpace(v[idx+len(sepRewrite):]),
+	}
+	if len(rule.from) == 0 || len(rule.to) == 0 {
+		return nil, fmt.Errorf("parseURLRewrite: empty URL in %q", v)
+	}
+
+	return rule, nil
+}
+
+// String return the rule in the same format as in database.
+func (rule *urlRewrite) String() string {
+	return rule.from + " " + sepRewrite + " " + rule.to
+}
+
+// urlRewrites contains list of rules to rewrite package remote URL.
+type urlRewrites []*urlRewrite
+
+// rewrite the canonical URL using the rule with the longest matched prefix.
+// If no rule match, it will return the URL as is.
+func (rules urlRewrites) rewrite(url string) string {
+	var match *urlRewrite
+
+	for _, rule := range rules {
+		if !strings.HasPrefix(url, rule.from) {
+			continue
+		}
+		if match == nil || len(rule.from) > len(match.from) {
+			match = rule
+		}
+	}
+	if match == nil {
+		return url
+	}
+
+	return match.to + url[len(match.from):]
+}
+
+// canonical revert the rewritten URL into their canonical URL using the
+// rule with the longest matched prefix.
+// If no rule match, it will return the URL as is.
+func (rules urlRewrites) canonical(url string) string {
+	var match *urlRewrite
+
+	for _, rule := range rules {
+		if !strings.HasPrefix(url, rule.to) {
+			continue
+		}
+		if match == nil || len(rule.to) > len(match.to) {
+			match = rule
+		}
+	}
+	if match == nil {
+		return url
+	}
+
+	return match.from + url[len(match.to):]
+}
@@ -0,0 +1,85 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/ini"
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestParseURLRewrite(t *testing.T) {
+	cases := []struct {
+		in     string
+		exp    *urlRewrite
+		expErr string
+	}{{
+		in:     "https://github.com/",
+		expErr: `parseURLRewrite: missing "=>" in "https://github.com/"`,
+	}, {
+		in:     "https://github.com/ =>",
+		expErr: `parseURLRewrite: empty URL in "https://github.com/ =>"`,
+	}, {
+		in: " https://github.com/ => git@git.internal:github/ ",
+		exp: &urlRewrite{
+			from: "https://github.com/",
+			to:   "git@git.internal:github/",
+		},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.in)
+
+		got, err := parseURLRewrite(c.in)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "urlRewrite", c.exp, got)
+	}
+}
+
+func TestURLRewrites(t *testing.T) {
+	in, err := ini.Parse([]byte(`[beku]
+rewrite = https://github.com/ => https://gitea.internal/github/
+rewrite = https://github.com/private/ => git@git.internal:private/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rules urlRewrites
+
+	for _, v := range in.Gets(sectionBeku, "", keyRewrite) {
+		rule, err := parseURLRewrite(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+
+	cases := []struct {
+		canonical string
+		rewritten string
+	}{{
+		canonical: "https://github.com/shuLhan/beku",
+		rewritten: "https://gitea.internal/github/shuLhan/beku",
+	}, {
+		canonical: "https://github.com/private/repo",
+		rewritten: "git@git.internal:private/repo",
+	}, {
+		canonical: "https://go.googlesource.com/text",
+		rewritten: "https://go.googlesource.com/text",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.canonical)
+
+		test.Assert(t, "rewrite", c.rewritten, rules.rewrite(c.canonical))
+		test.Assert(t, "canonical", c.canonical, rules.canonical(c.rewritten))
+	}
+}
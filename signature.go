@@ -0,0 +1,129 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"os"
+)
+
+// List of signature policy values.
+//
+// The policy can be set globally in "[beku]" section or per package in
+// "[package]" section of database, using the key "signature".
+// The policy on package override the global policy.
+//
+//	[beku]
+//	signature = require
+//	keyring = /home/user/.gnupg
+//
+//	[package "github.com/shuLhan/share"]
+//	signature = none
+const (
+	// SignatureRequire require the package version to be a signed tag or
+	// commit.
+	SignatureRequire = "require"
+
+	// SignatureNone does not verify the package version signature.
+	SignatureNone = "none"
+)
+
+// signaturePolicy define the global policy to verify the signature of
+// package version.
+//
+// If keyring is a directory, it is used as the GnuPG home directory.
+// If keyring is a file, it is used as the SSH allowed signers file.
+// If keyring is empty, git will use the user's default configuration.
+type signaturePolicy struct {
+	require bool
+	keyring string
+}
+
+// newSignaturePolicy create new signature policy from value of "signature"
+// and "keyring" keys in database.
+func newSignaturePolicy(policy, keyring string) (sp *signaturePolicy, err error) {
+	switch policy {
+	case "", SignatureNone:
+	case SignatureRequire:
+	default:
+		return nil, fmt.Errorf("newSignaturePolicy: unknown policy %q", policy)
+	}
+
+	sp = &signaturePolicy{
+		require: policy == SignatureRequire,
+		keyring: keyring,
+	}
+
+	return sp, nil
+}
+
+// String return the policy value as in database.
+func (sp *signaturePolicy) String() string {
+	if sp.require {
+		return SignatureRequire
+	}
+	return SignatureNone
+}
+
+// gitConfig return the git options and environment variables to verify the
+// signature using the keyring.
+// It will return an error if the keyring does not exist or can not be
+// read.
+func (sp *signaturePolicy) gitConfig() (args, envs []string, err error) {
+	if len(sp.keyring) == 0 {
+		return nil, nil, nil
+	}
+
+	f, err := os.Open(sp.keyring)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gitConfig: keyring: %w", err)
+	}
+
+	fi, err := f.Stat()
+	_ = f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("gitConfig: keyring: %w", err)
+	}
+	if fi.IsDir() {
+		return nil, []string{"GNUPGHOME=" + sp.keyring}, nil
+	}
+
+	return []string{"-c", "gpg.ssh.allowedSignersFile=" + sp.keyring}, nil, nil
+}
+
+// isSignatureRequired will return true if the package version must be
+// signed, based on the package and global policy.
+func (pkg *Package) isSignatureRequired() bool {
+	switch pkg.Signature {
+	case SignatureRequire:
+		return true
+	case SignatureNone:
+		return false
+	}
+	return pkg.signature != nil && pkg.signature.require
+}
+
+// verifySignature verify that the version is signed tag or commit, only if
+// the package require it.
+// It will return an error ErrUnsigned if the version is not signed or its
+// signature can not be verified.
+func (pkg *Package) verifySignature(version string) (err error) {
+	if !pkg.isSignatureRequired() {
+		return nil
+	}
+	if pkg.vcsMode == VCSModeGit {
+		err = pkg.gitVerifySignature(version)
+	}
+	return err
+}
+
+// skipUnsigned record the package that is skipped by operation op because
+// its version is not signed.
+func (env *Env) skipUnsigned(pkg *Package, op string, err error) {
+	env.log.Printf("[ENV] %s %s >>> Skipped, %s\n", op, pkg.ImportPath, err)
+
+	env.pkgsSkipped = append(env.pkgsSkipped,
+		fmt.Sprintf("%s (%s)", pkg.ImportPath, ErrUnsigned))
+}
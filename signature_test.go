@@ -0,0 +1,257 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestIsSignatureRequired(t *testing.T) {
+	global := &signaturePolicy{require: true}
+
+	cases := []struct {
+		desc string
+		pkg  *Package
+		exp  bool
+	}{{
+		desc: "Without policy",
+		pkg:  &Package{},
+	}, {
+		desc: "With global policy",
+		pkg:  &Package{signature: global},
+		exp:  true,
+	}, {
+		desc: "With package policy none",
+		pkg:  &Package{Signature: SignatureNone, signature: global},
+	}, {
+		desc: "With package policy require",
+		pkg:  &Package{Signature: SignatureRequire},
+		exp:  true,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		test.Assert(t, "isSignatureRequired", c.exp,
+			c.pkg.isSignatureRequired())
+	}
+}
+
+func TestNewSignaturePolicy(t *testing.T) {
+	cases := []struct {
+		desc    string
+		policy  string
+		exp     string
+		expErr  string
+		keyring string
+	}{{
+		desc: "With empty policy",
+		exp:  SignatureNone,
+	}, {
+		desc:   "With require",
+		policy: SignatureRequire,
+		exp:    SignatureRequire,
+	}, {
+		desc:   "With unknown policy",
+		policy: "always",
+		expErr: `newSignaturePolicy: unknown policy "always"`,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		sp, err := newSignaturePolicy(c.policy, c.keyring)
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "String", c.exp, sp.String())
+	}
+}
+
+func TestSignaturePolicyGitConfig(t *testing.T) {
+	dir := t.TempDir()
+	signersFile := filepath.Join(dir, "allowed_signers")
+	missing := filepath.Join(dir, "missing")
+
+	err := os.WriteFile(signersFile, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc    string
+		keyring string
+		expArgs []string
+		expEnvs []string
+		expErr  string
+	}{{
+		desc: "Without keyring",
+	}, {
+		desc:    "With directory",
+		keyring: dir,
+		expEnvs: []string{"GNUPGHOME=" + dir},
+	}, {
+		desc:    "With file",
+		keyring: signersFile,
+		expArgs: []string{"-c", "gpg.ssh.allowedSignersFile=" + signersFile},
+	}, {
+		desc:    "With missing keyring",
+		keyring: missing,
+		expErr: "gitConfig: keyring: open " + missing +
+			": no such file or directory",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		sp := &signaturePolicy{keyring: c.keyring}
+
+		args, envs, err := sp.gitConfig()
+		if err != nil {
+			test.Assert(t, "err", c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, "args", c.expArgs, args)
+		test.Assert(t, "envs", c.expEnvs, envs)
+	}
+}
+
+func TestGitVerifySignature(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+
+	dir := t.TempDir()
+	repoDir := filepath.Join(dir, "repo")
+	keyFile := filepath.Join(dir, "id_ed25519")
+	signersFile := filepath.Join(dir, "allowed_signers")
+
+	cmd := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "",
+		"-f", keyFile)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh-keygen: %s: %s", err, out)
+	}
+
+	pubKey, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(signersFile,
+		append([]byte("beku@localhost "), pubKey...), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(repoDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "init", "--quiet",
+		"--initial-branch="+gitDefBranch)
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "unsigned")
+	testGit(t, repoDir, "2018-01-01T00:00:00Z", "tag", "v0.1.0")
+	testGit(t, repoDir, "2018-01-02T00:00:00Z",
+		"-c", "gpg.format=ssh", "-c", "user.signingkey="+keyFile,
+		"commit", "--quiet", "--allow-empty", "-S", "-m", "signed")
+	testGit(t, repoDir, "2018-01-02T00:00:00Z", "tag", "v0.2.0")
+
+	pkg := &Package{
+		ImportPath: "beku/signature",
+		FullPath:   repoDir,
+		vcsMode:    VCSModeGit,
+		signature: &signaturePolicy{
+			require: true,
+			keyring: signersFile,
+		},
+	}
+
+	err = pkg.verifySignature("v0.1.0")
+	test.Assert(t, "v0.1.0 is unsigned", true, errors.Is(err, ErrUnsigned))
+	test.Assert(t, "v0.1.0 error contains git message", true,
+		strings.Contains(err.Error(), "cannot verify a non-tag object"))
+
+	err = pkg.verifySignature("v0.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.Signature = SignatureNone
+
+	err = pkg.verifySignature("v0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.Signature = SignatureRequire
+	pkg.signature.keyring = filepath.Join(dir, "missing")
+
+	err = pkg.verifySignature("v0.2.0")
+	test.Assert(t, "missing keyring is not ErrUnsigned", false,
+		errors.Is(err, ErrUnsigned))
+	test.Assert(t, "missing keyring is os.ErrNotExist", true,
+		errors.Is(err, os.ErrNotExist))
+}
+
+func TestPackageUpdateUnsigned(t *testing.T) {
+	var (
+		pkg   = testCreateDirtyRepo(t)
+		other = testCreateDirtyRepo(t)
+	)
+
+	testGit(t, other.FullPath, "2018-01-02T00:00:00Z", "commit", "--quiet",
+		"--allow-empty", "-m", "unsigned")
+	testGit(t, other.FullPath, "2018-01-02T00:00:00Z", "tag", "v0.1.0")
+	testGit(t, other.FullPath, "2018-01-02T00:00:00Z", "push", "--quiet",
+		"--tags", "origin", gitDefBranch)
+
+	pkg.RemoteName = gitDefRemoteName
+	pkg.RemoteURL = filepath.Join(filepath.Dir(pkg.FullPath), "remote.git")
+	pkg.Signature = SignatureRequire
+
+	newPkg := &Package{
+		ImportPath: "example.com/other",
+		FullPath:   filepath.Join(t.TempDir(), "other"),
+		RemoteName: gitDefRemoteName,
+		RemoteURL:  filepath.Join(filepath.Dir(other.FullPath), "remote.git"),
+		Version:    "v0.1.0",
+		vcsMode:    VCSModeGit,
+	}
+
+	head, err := pkg.gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pkg.Update(newPkg)
+	test.Assert(t, "ErrUnsigned", true, errors.Is(err, ErrUnsigned))
+
+	_, err = os.Stat(newPkg.FullPath)
+	test.Assert(t, "new directory is not exist", true, os.IsNotExist(err))
+
+	gotURL, err := pkg.gitOutput("remote", "get-url", gitDefRemoteName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "remote URL", pkg.RemoteURL, gotURL)
+
+	gotHead, err := pkg.gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, "HEAD", head, gotHead)
+}
@@ -0,0 +1,172 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/vcs"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+const (
+	// DefVanityCacheName define default file name, where the resolved
+	// vanity import paths will be saved and loaded.
+	DefVanityCacheName = "vanity.cache"
+
+	// DefVanityTTL define default duration of resolved vanity import path
+	// will be kept in cache.
+	DefVanityTTL = 7 * 24 * time.Hour
+)
+
+const (
+	sectionVanity = "vanity"
+
+	keyVanityRepo = "repo"
+	keyVanityTime = "time"
+)
+
+// vanityCache contains the repository root of vanity import paths that
+// has been resolved through network, using HTTP "?go-get=1" discovery.
+type vanityCache struct {
+	file string
+	db   *ini.Ini
+	log  *Logger
+	ttl  time.Duration
+}
+
+// newVanityCache create new cache that will be loaded from and saved into
+// file.
+func newVanityCache(file string, ttl time.Duration) (cache *vanityCache) {
+	return &vanityCache{
+		file: file,
+		ttl:  ttl,
+	}
+}
+
+// load the cache from file, only if its not loaded yet.
+func (cache *vanityCache) load() {
+	if cache.db != nil {
+		return
+	}
+
+	var err error
+
+	cache.db, err = ini.Open(cache.file)
+	if err != nil {
+		if !os.IsNotExist(err) && cache.log.IsVerbose() {
+			cache.log.Printf("[VANITY] load >>> %s\n", err)
+		}
+		cache.db = &ini.Ini{}
+	}
+}
+
+// get the repository root of import path from cache.
+// It will return nil if import path is not cached or the cache is expired.
+func (cache *vanityCache) get(importPath string) (rr *vcs.RepoRoot) {
+	if cache == nil {
+		return nil
+	}
+
+	cache.load()
+
+	now := time.Now()
+
+	for _, sec := range cache.db.Subs(sectionVanity) {
+		root := sec.SubName()
+		if importPath != root && !strings.HasPrefix(importPath, root+sepImport) {
+			continue
+		}
+		if rr != nil && len(rr.Root) >= len(root) {
+			continue
+		}
+
+		vcsCmd := vcs.ByCmd(sec.Val(keyVCSMode))
+		if vcsCmd == nil {
+			continue
+		}
+
+		ts, err := strconv.ParseInt(sec.Val(keyVanityTime), 10, 64)
+		if err != nil {
+			continue
+		}
+		if now.Sub(time.Unix(ts, 0)) > cache.ttl {
+			continue
+		}
+
+		rr = &vcs.RepoRoot{
+			VCS:  vcsCmd,
+			Repo: sec.Val(keyVanityRepo),
+			Root: root,
+		}
+	}
+
+	return rr
+}
+
+// set store the repository root into cache and save the cache into file.
+func (cache *vanityCache) set(rr *vcs.RepoRoot) (err error) {
+	if cache == nil {
+		return nil
+	}
+
+	cache.load()
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	cache.db.Set(sectionVanity, rr.Root, keyVCSMode, rr.VCS.Cmd)
+	cache.db.Set(sectionVanity, rr.Root, keyVanityRepo, rr.Repo)
+	cache.db.Set(sectionVanity, rr.Root, keyVanityTime, ts)
+
+	err = os.MkdirAll(filepath.Dir(cache.file), 0700)
+	if err != nil {
+		return fmt.Errorf("vanityCache.set: %w", err)
+	}
+
+	err = cache.db.Save(cache.file)
+	if err != nil {
+		return fmt.Errorf("vanityCache.set: %w", err)
+	}
+
+	return nil
+}
+
+// repoRootForImportPath resolve the repository root of import path.
+// The import path on known hosting sites (for example, github.com) is
+// resolved statically.
+// The vanity import path is resolved from cache first, and only if its not
+// cached or expired it will be resolved through network.
+func (cache *vanityCache) repoRootForImportPath(importPath string) (
+	rr *vcs.RepoRoot, err error,
+) {
+	rr, err = vcs.RepoRootForImportPathStatic(importPath, "")
+	if err == nil {
+		return rr, nil
+	}
+
+	rr = cache.get(importPath)
+	if rr != nil {
+		cache.log.Verbosef("[VANITY] cached >>> %s %s\n", importPath, rr.Repo)
+		return rr, nil
+	}
+
+	rr, err = vcs.RepoRootForImportPath(importPath, cache.log.IsVerbose())
+	if err != nil {
+		return nil, err
+	}
+
+	err = cache.set(rr)
+	if err != nil {
+		cache.log.Warnf("[VANITY] %s\n", err)
+	}
+
+	return rr, nil
+}
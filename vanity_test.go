@@ -0,0 +1,102 @@
+// Copyright 2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package beku
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/vcs"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestVanityCache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "var", DefVanityCacheName)
+
+	content := `[vanity "example.com/expired"]
+vcs = git
+repo = https://example.com/expired
+time = 1
+`
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(file, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := newVanityCache(file, DefVanityTTL)
+
+	err = cache.set(&vcs.RepoRoot{
+		VCS:  vcs.ByCmd(VCSModeGit),
+		Repo: "https://git.example.com/pkg",
+		Root: "example.com/pkg",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the cache from file.
+	cache = newVanityCache(file, DefVanityTTL)
+
+	cases := []struct {
+		importPath string
+		expRepo    string
+	}{{
+		importPath: "example.com/pkg",
+		expRepo:    "https://git.example.com/pkg",
+	}, {
+		importPath: "example.com/pkg/sub",
+		expRepo:    "https://git.example.com/pkg",
+	}, {
+		importPath: "example.com/pkgsub",
+	}, {
+		importPath: "example.com/expired",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.importPath)
+
+		var gotRepo string
+
+		rr := cache.get(c.importPath)
+		if rr != nil {
+			gotRepo = rr.Repo
+		}
+
+		test.Assert(t, "Repo", c.expRepo, gotRepo)
+	}
+}
+
+func TestVanityCacheRepoRootForImportPath(t *testing.T) {
+	cache := newVanityCache(filepath.Join(t.TempDir(), DefVanityCacheName),
+		DefVanityTTL)
+
+	cases := []struct {
+		importPath string
+		expRepo    string
+		expRoot    string
+	}{{
+		importPath: "github.com/shuLhan/beku/cmd/beku",
+		expRepo:    "https://github.com/shuLhan/beku",
+		expRoot:    "github.com/shuLhan/beku",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.importPath)
+
+		rr, err := cache.repoRootForImportPath(c.importPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, "Repo", c.expRepo, rr.Repo)
+		test.Assert(t, "Root", c.expRoot, rr.Root)
+	}
+}